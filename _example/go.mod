@@ -11,3 +11,5 @@ require (
 	github.com/leonelquinteros/gotext v1.5.1 // indirect
 	golang.org/x/text v0.3.7 // indirect
 )
+
+replace github.com/yzzyx/pongo-trans => ../
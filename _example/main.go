@@ -28,8 +28,15 @@ func main() {
 	// If the files should be loaded from an embedded FS, use this line instead
 	// t, err := trans.NewTemplateTranslator(localeFS, "locales")
 
-	pongo2.RegisterTag("trans", trans.NewTransTag(t))
-	pongo2.RegisterTag("blocktrans", trans.NewBlockTransTag(t))
+	// Select the language 'qps' to see pseudo-localized text, which makes it easy to spot untranslated strings
+	pt := trans.NewPseudoTranslator(t, "qps")
+
+	pongo2.RegisterTag("trans", trans.NewTransTag(pt))
+	pongo2.RegisterTag("blocktrans", trans.NewBlockTransTag(pt))
+	pongo2.RegisterTag("language", trans.NewLanguageTag())
+	pongo2.RegisterTag("get_available_languages", trans.NewGetAvailableLanguagesTag(pt))
+	pongo2.RegisterTag("get_current_language", trans.NewGetCurrentLanguageTag())
+	pongo2.RegisterTag("get_language_info", trans.NewGetLanguageInfoTag(pt))
 
 	tmpl, _ := pongo2.FromString(`{% trans "Please translate this!" %}`)
 	result, _ := tmpl.Execute(pongo2.Context{
@@ -52,11 +59,6 @@ func main() {
 	srv.Addr = "127.0.0.1:9911"
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		// Normally this would probably be attached to a session or similar
-		language := req.FormValue("lang")
-		if language == "" {
-			language = "en_GB"
-		}
 		vc := atomic.AddInt32(&visitCount, 1)
 
 		tmpl, err := ts.FromFile("index.html")
@@ -66,12 +68,11 @@ func main() {
 			return
 		}
 
-		err = tmpl.ExecuteWriter(pongo2.Context{
-			"_language": language,
-			"_domain":   "default",
-			"p2":        "pongo2",
-			"visited":   vc,
-		}, w)
+		// The language is negotiated by the middleware, from the 'lang'-parameter or the 'Accept-Language'-header
+		err = tmpl.ExecuteWriter(trans.TemplateContext(req.Context()).Update(pongo2.Context{
+			"p2":      "pongo2",
+			"visited": vc,
+		}), w)
 
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -79,7 +80,10 @@ func main() {
 			return
 		}
 	})
-	srv.Handler = mux
+	srv.Handler = trans.LanguageMiddleware(pt,
+		trans.WithDefaultLanguage("en_GB"),
+		trans.WithDomain("default"),
+	)(mux)
 
 	// Cancel on ctrl+c
 	idleConnsClosed := make(chan struct{})
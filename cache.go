@@ -0,0 +1,126 @@
+package trans
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// defaultTemplateCacheSize is the default maximum number of compiled translations kept in a cache
+const defaultTemplateCacheSize = 10000
+
+// ReloadNotifier is implemented by translators whose translations can change, e.g. TemplateTranslator.
+// The tags use it to clear their cache when the translations are reloaded.
+type ReloadNotifier interface {
+	OnReload(fn func())
+}
+
+// templateKey identifies a translated string.
+// The translation itself is part of the key, so that different plural forms are cached separately,
+// and a stale template is never used even if the cache hasn't been cleared yet
+type templateKey struct {
+	language string
+	domain   string
+	context  string
+	id       string
+	plural   string
+	content  string
+
+	autoescape bool
+
+	// The options of the tag change how the template is compiled, and tags with different options may share the cache
+	untrusted  bool
+	restricted bool
+	bound      string // The variables bound by the tag, which are allowed in restricted translations
+}
+
+// templateCache contains templates compiled from translated strings
+type templateCache struct {
+	size int
+
+	mu        sync.RWMutex
+	templates map[templateKey]*pongo2.Template
+}
+
+// templateCacheID identifies the cache shared by the tags using the same translator and cache size
+type templateCacheID struct {
+	translator Translator
+	size       int
+}
+
+// templateCaches contains the caches of translators that can be reloaded. Since the tags share their cache,
+// only one function is registered with OnReload per translator and size, no matter how many times
+// the tags are created, e.g. with pongo2.ReplaceTag.
+var (
+	templateCachesMu sync.Mutex
+	templateCaches   = map[templateCacheID]*templateCache{}
+)
+
+// newTemplateCache returns a cache holding at most size templates.
+// If translator implements ReloadNotifier, the cache is shared with the other tags using the same translator,
+// and cleared every time the translations are reloaded. nil is returned if caching is disabled.
+func newTemplateCache(translator Translator, size int) *templateCache {
+	if size <= 0 {
+		return nil
+	}
+
+	// Translators that aren't pointers may not be usable as map keys. Their caches are never cleared, which
+	// is safe since the translation is part of the key, but stale templates are only removed when the cache is full
+	notifier, ok := translator.(ReloadNotifier)
+	if !ok || reflect.ValueOf(translator).Kind() != reflect.Ptr {
+		return &templateCache{
+			size:      size,
+			templates: map[templateKey]*pongo2.Template{},
+		}
+	}
+
+	templateCachesMu.Lock()
+	defer templateCachesMu.Unlock()
+
+	id := templateCacheID{translator: translator, size: size}
+	if c, ok := templateCaches[id]; ok {
+		return c
+	}
+
+	c := &templateCache{
+		size:      size,
+		templates: map[templateKey]*pongo2.Template{},
+	}
+	templateCaches[id] = c
+	notifier.OnReload(c.clear)
+	return c
+}
+
+func (c *templateCache) get(key templateKey) *pongo2.Template {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.templates[key]
+}
+
+func (c *templateCache) add(key templateKey, tpl *pongo2.Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Evict an arbitrary entry when the cache is full.
+	// This only happens if the strings to translate are generated dynamically
+	if len(c.templates) >= c.size {
+		for k := range c.templates {
+			delete(c.templates, k)
+			break
+		}
+	}
+	c.templates[key] = tpl
+}
+
+func (c *templateCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.templates)
+}
+
+func (c *templateCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.templates = map[templateKey]*pongo2.Template{}
+}
@@ -0,0 +1,374 @@
+// Package catalog reads and writes gettext message catalogs (.po and .mo files), i18next-style JSON catalogs and XLIFF-files,
+// and reads Project Fluent resources (.ftl)
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext/plurals"
+)
+
+// Message describes a single entry in a catalog
+type Message struct {
+	Context  string
+	ID       string
+	IDPlural string
+
+	// Str contains the translation. Plural messages have one entry per plural form
+	Str []string
+
+	TranslatorComments []string // Lines starting with '# '
+	ExtractedComments  []string // Lines starting with '#.'
+	References         []string // Lines starting with '#:', split on whitespace
+	Flags              []string // Lines starting with '#,', split on ','
+
+	// Previous values of the message, used for fuzzy messages (lines starting with '#|')
+	PreviousContext  string
+	PreviousID       string
+	PreviousIDPlural string
+
+	// Obsolete messages are kept in the catalog, but are never used for translations
+	Obsolete bool
+}
+
+// HasFlag checks if the message has a specific flag set, e.g. 'fuzzy'
+func (m *Message) HasFlag(flag string) bool {
+	for _, f := range m.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFuzzy checks if the message is marked as fuzzy
+func (m *Message) IsFuzzy() bool {
+	return m.HasFlag("fuzzy")
+}
+
+// IsTranslated checks if all forms of the message has been translated
+func (m *Message) IsTranslated() bool {
+	if len(m.Str) == 0 {
+		return false
+	}
+
+	for _, str := range m.Str {
+		if str == "" {
+			return false
+		}
+	}
+	return true
+}
+
+type messageKey struct {
+	context string
+	id      string
+}
+
+// Catalog contains all messages from a single .po, .mo, .json, .xlf or .ftl file
+type Catalog struct {
+	// Messages contains all messages in the order they were read, including the header and obsolete messages.
+	// Use Add to add new messages, in order to keep the catalog index up to date.
+	Messages []*Message
+
+	index      map[messageKey]*Message
+	nplurals   int
+	pluralExpr plurals.Expression
+	pluralFunc func(n int) int
+}
+
+// New creates an empty catalog
+func New() *Catalog {
+	return &Catalog{index: map[messageKey]*Message{}}
+}
+
+// NewTemplate creates a catalog template (.pot), containing only the standard header
+func NewTemplate() *Catalog {
+	c := New()
+	c.Add(&Message{
+		Str: []string{"Project-Id-Version: \n" +
+			"POT-Creation-Date: " + time.Now().Format("2006-01-02 15:04-0700") + "\n" +
+			"MIME-Version: 1.0\n" +
+			"Content-Type: text/plain; charset=UTF-8\n" +
+			"Content-Transfer-Encoding: 8bit\n" +
+			"Plural-Forms: nplurals=INTEGER; plural=EXPRESSION;\n"},
+		Flags: []string{"fuzzy"},
+	})
+	return c
+}
+
+// Add adds a message to the catalog, replacing any existing message with the same context and id
+func (c *Catalog) Add(m *Message) {
+	if m.Obsolete {
+		c.Messages = append(c.Messages, m)
+		return
+	}
+
+	key := messageKey{context: m.Context, id: m.ID}
+	if old, ok := c.index[key]; ok {
+		for k := range c.Messages {
+			if c.Messages[k] == old {
+				c.Messages[k] = m
+				break
+			}
+		}
+	} else {
+		c.Messages = append(c.Messages, m)
+	}
+	c.index[key] = m
+
+	if m.ID == "" && m.Context == "" {
+		c.parsePluralForms()
+	}
+}
+
+// Lookup returns the message with a specific context and id, or nil if it does not exist.
+// Obsolete messages are never returned.
+func (c *Catalog) Lookup(context, id string) *Message {
+	return c.index[messageKey{context: context, id: id}]
+}
+
+// HeaderMessage returns the message containing the catalog header (i.e. the message with an empty msgid)
+func (c *Catalog) HeaderMessage() *Message {
+	return c.Lookup("", "")
+}
+
+// Header returns the value of a field in the catalog header, e.g. 'Plural-Forms'.
+// The field name is case-insensitive
+func (c *Catalog) Header(key string) string {
+	h := c.HeaderMessage()
+	if h == nil || len(h.Str) == 0 {
+		return ""
+	}
+
+	for _, line := range strings.Split(h.Str[0], "\n") {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+
+		if strings.EqualFold(strings.TrimSpace(line[:idx]), key) {
+			return strings.TrimSpace(line[idx+1:])
+		}
+	}
+	return ""
+}
+
+// SetHeader sets the value of a field in the catalog header, adding the field if it does not exist.
+// A header message is created if the catalog doesn't have one.
+func (c *Catalog) SetHeader(key, value string) {
+	h := c.HeaderMessage()
+	if h == nil {
+		h = &Message{}
+		c.Messages = append([]*Message{h}, c.Messages...)
+		c.index[messageKey{}] = h
+	}
+	if len(h.Str) == 0 {
+		h.Str = []string{""}
+	}
+
+	lines := strings.SplitAfter(h.Str[0], "\n")
+	found := false
+	for k, line := range lines {
+		idx := strings.Index(line, ":")
+		if idx >= 0 && strings.EqualFold(strings.TrimSpace(line[:idx]), key) {
+			lines[k] = key + ": " + value + "\n"
+			found = true
+			break
+		}
+	}
+
+	str := strings.Join(lines, "")
+	if !found {
+		if str != "" && !strings.HasSuffix(str, "\n") {
+			str += "\n"
+		}
+		str += key + ": " + value + "\n"
+	}
+	h.Str[0] = str
+	c.parsePluralForms()
+}
+
+// NPlurals returns the number of plural forms, as specified in the 'Plural-Forms'-header.
+// If the header is missing or malformed, 0 is returned
+func (c *Catalog) NPlurals() int {
+	return c.nplurals
+}
+
+// PluralForm returns the index of the plural form to use for n
+func (c *Catalog) PluralForm(n int) int {
+	// Use the germanic plural rule if no plural forms are specified
+	if c.pluralFunc != nil {
+		return c.pluralFunc(n)
+	}
+	if c.pluralExpr == nil {
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}
+	return c.pluralExpr.Eval(uint32(n))
+}
+
+// Translation returns the translation of a message, and whether a translation exists.
+// Messages marked as fuzzy are not used, in the same way as they're skipped when compiling .mo-files
+func (c *Catalog) Translation(context, id string) (string, bool) {
+	m := c.Lookup(context, id)
+	if m == nil || m.IsFuzzy() || len(m.Str) == 0 || m.Str[0] == "" {
+		return "", false
+	}
+	return m.Str[0], true
+}
+
+// PluralTranslation returns the translation of the plural form of a message to use for n,
+// and whether a translation exists. Messages marked as fuzzy are not used
+func (c *Catalog) PluralTranslation(context, id string, n int) (string, bool) {
+	m := c.Lookup(context, id)
+	if m == nil || m.IsFuzzy() {
+		return "", false
+	}
+
+	idx := c.PluralForm(n)
+	if idx < 0 || idx >= len(m.Str) || m.Str[idx] == "" {
+		return "", false
+	}
+	return m.Str[idx], true
+}
+
+// CheckPluralForms checks that the 'Plural-Forms'-header is well-formed, i.e. that it contains a positive nplurals
+// and a plural expression that only selects forms from 0 to nplurals-1.
+// Catalogs without a 'Plural-Forms'-header are not checked
+func (c *Catalog) CheckPluralForms() error {
+	header := c.Header("Plural-Forms")
+	if header == "" {
+		return nil
+	}
+
+	nplurals := -1
+	var expr plurals.Expression
+	for _, part := range strings.Split(header, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid Plural-Forms %q", header)
+		}
+
+		value := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "nplurals":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid nplurals %q in Plural-Forms", value)
+			}
+			nplurals = n
+		case "plural":
+			var err error
+			if expr, err = plurals.Compile(value); err != nil {
+				return fmt.Errorf("invalid plural expression %q in Plural-Forms: %v", value, err)
+			} else if expr == nil {
+				return fmt.Errorf("unsupported plural expression %q in Plural-Forms", value)
+			}
+		default:
+			return fmt.Errorf("unknown field %q in Plural-Forms", strings.TrimSpace(kv[0]))
+		}
+	}
+
+	if nplurals < 0 {
+		return errors.New("nplurals is missing in Plural-Forms")
+	}
+	if expr == nil {
+		return errors.New("plural expression is missing in Plural-Forms")
+	}
+
+	// Plural rules tend to repeat after 100 (e.g. for n%100 or n%10), so checking the first 1000 numbers should be enough
+	for n := 0; n < 1000; n++ {
+		if form := expr.Eval(uint32(n)); form < 0 || form >= nplurals {
+			return fmt.Errorf("plural expression in Plural-Forms selects form %d for n=%d, but nplurals is %d", form, n, nplurals)
+		}
+	}
+	return nil
+}
+
+// SetPluralFunc sets the function used to select the plural form for n, for catalogs where the plural forms
+// cannot be described by a 'Plural-Forms'-header. The function must return a form from 0 to nplurals-1.
+// Changing the 'Plural-Forms'-header afterwards removes the function.
+func (c *Catalog) SetPluralFunc(nplurals int, fn func(n int) int) {
+	c.nplurals = nplurals
+	c.pluralExpr = nil
+	c.pluralFunc = fn
+}
+
+// parsePluralForms parses the 'Plural-Forms'-header, e.g. 'nplurals=2; plural=n != 1;'
+func (c *Catalog) parsePluralForms() {
+	c.nplurals = 0
+	c.pluralExpr = nil
+	c.pluralFunc = nil
+
+	for _, part := range strings.Split(c.Header("Plural-Forms"), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "nplurals":
+			c.nplurals, _ = strconv.Atoi(strings.TrimSpace(kv[1]))
+		case "plural":
+			if expr, err := plurals.Compile(strings.TrimSpace(kv[1])); err == nil {
+				c.pluralExpr = expr
+			}
+		}
+	}
+}
+
+// Stats contains the number of messages in a catalog, by translation status
+type Stats struct {
+	Total        int `json:"total"`
+	Translated   int `json:"translated"`
+	Fuzzy        int `json:"fuzzy"`
+	Untranslated int `json:"untranslated"`
+}
+
+// Percent returns the percentage of the messages that has been translated. Fuzzy messages are not counted as translated.
+// An empty catalog is considered completely translated
+func (s Stats) Percent() float64 {
+	if s.Total == 0 {
+		return 100
+	}
+	return float64(s.Translated) * 100 / float64(s.Total)
+}
+
+// Stats counts the messages in the catalog, excluding the header and obsolete messages.
+// Note that .mo-files only contain translated messages, so they are always reported as completely translated.
+func (c *Catalog) Stats() Stats {
+	return c.StatsFunc(nil)
+}
+
+// StatsFunc counts the messages in the catalog like Stats, but only the messages for which include returns true.
+// All messages are included if include is nil
+func (c *Catalog) StatsFunc(include func(m *Message) bool) Stats {
+	var s Stats
+	for _, m := range c.Messages {
+		if m.Obsolete || (m.ID == "" && m.Context == "") || (include != nil && !include(m)) {
+			continue
+		}
+
+		s.Total++
+		switch {
+		case m.IsFuzzy():
+			s.Fuzzy++
+		case m.IsTranslated():
+			s.Translated++
+		default:
+			s.Untranslated++
+		}
+	}
+	return s
+}
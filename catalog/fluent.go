@@ -0,0 +1,200 @@
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fluentEntry matches the first line of a message or term, e.g. 'hello-user = Hello, { $name }!'
+var fluentEntry = regexp.MustCompile(`^(-?[a-zA-Z][a-zA-Z0-9_-]*) *= *(.*)$`)
+
+// fluentAttribute matches the first line of an attribute, e.g. '    .placeholder = Your email'
+var fluentAttribute = regexp.MustCompile(`^[ \t]+\.([a-zA-Z][a-zA-Z0-9_-]*) *= *(.*)$`)
+
+// ParseFluent parses a Project Fluent resource (.ftl), where the message and term ids are used as msgid.
+// lang is the language of the translations (e.g. 'sv_SE').
+//
+// The value of each message and term is stored as a message with the id as msgid, and each attribute
+// is stored as a message with the id as msgid and the name of the attribute as context, so that
+// 'login-input' with the attribute '.placeholder' is available as the msgid 'login-input' with the context 'placeholder'.
+// Terms keep their leading '-', e.g. '-brand-name'.
+//
+// The translations are the patterns as written in the resource, with multiline patterns joined and dedented.
+// Placeables, e.g. '{ $name }' or select expressions, are left as is, and are resolved by trans.FluentTranslator.
+// Comments directly preceding a message are stored as extracted comments.
+func ParseFluent(data []byte, lang string) (*Catalog, error) {
+	p := &fluentParser{c: New()}
+	p.c.SetHeader("Language", lang)
+
+	for _, line := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		p.line++
+		if err := p.parseLine(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.finishEntry(); err != nil {
+		return nil, err
+	}
+	return p.c, nil
+}
+
+type fluentParser struct {
+	c    *Catalog
+	line int
+
+	comments []string // The comment preceding the current entry
+
+	id        string   // The id of the current entry, if any
+	attribute string   // The name of the current attribute, or "" for the value
+	pattern   []string // The lines of the current pattern. The first line is the text following '='
+	depth     int      // The number of open placeables in the current pattern
+	values    int      // The number of values and attributes found in the current entry
+}
+
+func (p *fluentParser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *fluentParser) parseLine(line string) error {
+	// Indented lines, blank lines, and all lines inside placeables belong to the current entry
+	if p.id != "" && (p.depth > 0 || line == "" || line[0] == ' ' || line[0] == '\t') {
+		if m := fluentAttribute.FindStringSubmatch(line); m != nil && p.depth == 0 {
+			if err := p.finishPattern(); err != nil {
+				return err
+			}
+			p.startPattern(m[1], m[2])
+			return nil
+		}
+
+		p.pattern = append(p.pattern, line)
+		p.depth = placeableDepth(p.depth, line)
+		return nil
+	}
+
+	if err := p.finishEntry(); err != nil {
+		return err
+	}
+
+	switch {
+	case strings.TrimSpace(line) == "":
+		// A comment is only attached to an entry directly following it
+		p.comments = nil
+
+	case line == "#" || strings.HasPrefix(line, "# "):
+		p.comments = append(p.comments, strings.TrimPrefix(strings.TrimPrefix(line, "#"), " "))
+
+	case strings.HasPrefix(line, "##"):
+		// Group and resource comments are not attached to any entry
+		p.comments = nil
+
+	default:
+		m := fluentEntry.FindStringSubmatch(line)
+		if m == nil {
+			return p.errorf("expected a message, a term or a comment")
+		}
+		p.id = m[1]
+		p.values = 0
+		p.startPattern("", m[2])
+	}
+	return nil
+}
+
+func (p *fluentParser) startPattern(attribute, text string) {
+	p.attribute = attribute
+	p.pattern = []string{text}
+	p.depth = placeableDepth(0, text)
+}
+
+// finishPattern adds the current pattern to the catalog
+func (p *fluentParser) finishPattern() error {
+	if p.depth > 0 {
+		return p.errorf("unclosed placeable in '%s'", p.id)
+	}
+
+	// The common indentation of the lines following the first is removed
+	indent := -1
+	for _, line := range p.pattern[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent < 0 {
+		indent = 0
+	}
+
+	lines := make([]string, 0, len(p.pattern))
+	if strings.TrimSpace(p.pattern[0]) != "" {
+		lines = append(lines, p.pattern[0])
+	}
+	for _, line := range p.pattern[1:] {
+		if len(line) >= indent {
+			line = line[indent:]
+		} else {
+			line = ""
+		}
+		lines = append(lines, line)
+	}
+	value := strings.TrimSpace(strings.Join(lines, "\n"))
+
+	if value == "" {
+		if p.attribute != "" {
+			return p.errorf("the attribute '%s' of '%s' has no value", p.attribute, p.id)
+		}
+		return nil
+	}
+
+	m := &Message{Context: p.attribute, ID: p.id, Str: []string{value}}
+	if p.attribute == "" {
+		m.ExtractedComments = p.comments
+	}
+	p.c.Add(m)
+	p.values++
+	return nil
+}
+
+// finishEntry adds the current entry, if any, to the catalog
+func (p *fluentParser) finishEntry() error {
+	if p.id == "" {
+		return nil
+	}
+
+	if err := p.finishPattern(); err != nil {
+		return err
+	}
+	if p.values == 0 {
+		return p.errorf("'%s' has neither a value nor attributes", p.id)
+	}
+	if strings.HasPrefix(p.id, "-") && p.c.Lookup("", p.id) == nil {
+		return p.errorf("the term '%s' has no value", p.id)
+	}
+
+	p.id = ""
+	p.comments = nil
+	return nil
+}
+
+// placeableDepth returns the number of open placeables after a line, where depth is the number of placeables
+// open before it. Braces in string literals inside placeables are ignored, also on the lines following the first
+func placeableDepth(depth int, line string) int {
+	inString := false
+	for k := 0; k < len(line); k++ {
+		switch c := line[k]; {
+		case inString && c == '\\':
+			k++
+		case inString:
+			inString = c != '"'
+		case c == '"' && depth > 0:
+			inString = true
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,87 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFluent(t *testing.T) {
+	ftl := `### Resource comment
+
+## Group comment
+
+-brand-name = Firefox
+    .gender = masculine
+
+# A welcome message
+hello-user = Hello, { $name }!
+
+emails =
+    { $unread ->
+        [one] You have one unread email.
+       *[other] You have { $unread } unread emails.
+    }
+
+login-input = Predefined value
+    .placeholder = email@example.com
+    .aria-label = Login input value
+
+# Not attached, since it's followed by a blank line
+
+multiline =
+    First line
+      indented
+
+    after blank
+only-attributes =
+    .title = Title
+literal = {
+    "{" }
+`
+
+	cat, err := ParseFluent([]byte(ftl), "en_US")
+	require.Nil(t, err)
+	require.Equal(t, "en_US", cat.Header("Language"))
+
+	tests := []struct {
+		context  string
+		id       string
+		expected string
+	}{
+		{id: "-brand-name", expected: "Firefox"},
+		{context: "gender", id: "-brand-name", expected: "masculine"},
+		{id: "hello-user", expected: "Hello, { $name }!"},
+		{id: "emails", expected: "{ $unread ->\n    [one] You have one unread email.\n   *[other] You have { $unread } unread emails.\n}"},
+		{id: "login-input", expected: "Predefined value"},
+		{context: "placeholder", id: "login-input", expected: "email@example.com"},
+		{context: "aria-label", id: "login-input", expected: "Login input value"},
+		{id: "multiline", expected: "First line\n  indented\n\nafter blank"},
+		{context: "title", id: "only-attributes", expected: "Title"},
+		{id: "literal", expected: "{\n\"{\" }"},
+	}
+
+	for k, tst := range tests {
+		tr, ok := cat.Translation(tst.context, tst.id)
+		require.Truef(t, ok, "test: %d, id: %s", k, tst.id)
+		require.Equalf(t, tst.expected, tr, "test: %d, id: %s", k, tst.id)
+	}
+
+	_, ok := cat.Translation("", "only-attributes")
+	require.False(t, ok)
+	require.Equal(t, []string{"A welcome message"}, cat.Lookup("", "hello-user").ExtractedComments)
+	require.Nil(t, cat.Lookup("", "multiline").ExtractedComments)
+
+	for k, data := range []string{
+		"not a message",
+		"unclosed = { $name",
+		"empty =",
+		"-term =\n    .attr = value",
+		"msg = value\n    .attr =",
+	} {
+		_, err := ParseFluent([]byte(data), "en")
+		require.NotNilf(t, err, "test: %d", k)
+		_, ok := err.(*ParseError)
+		require.Truef(t, ok, "test: %d", k)
+	}
+}
@@ -0,0 +1,182 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// jsonPluralForms contains the plural suffixes used in i18next-style JSON, in the order they are stored in Message.Str
+var jsonPluralForms = []struct {
+	suffix string
+	form   plural.Form
+}{
+	{"_zero", plural.Zero},
+	{"_one", plural.One},
+	{"_two", plural.Two},
+	{"_few", plural.Few},
+	{"_many", plural.Many},
+	{"_other", plural.Other},
+}
+
+// ParseJSON parses an i18next-style JSON-file, where the keys are used as msgid.
+// lang is the language of the translations (e.g. 'sv_SE'), and is used to select the plural forms.
+//
+// Nested objects are flattened, with the keys joined by '.', so {"nav": {"home": "Home"}} contains the msgid 'nav.home'.
+// Keys ending with a plural suffix ('_zero', '_one', '_two', '_few', '_many' or '_other') are combined into
+// plural messages, and the form is selected by the CLDR plural rules for the language.
+// A key such as 'friend_male' is available both as the msgid 'friend_male', and as the msgid 'friend' with
+// the context 'male'.
+func ParseJSON(data []byte, lang string) (*Catalog, error) {
+	type entry struct {
+		key   string
+		value string
+	}
+
+	var entries []entry
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// parseObject reads the members of an object, after the opening delimiter has been read
+	var parseObject func(prefix string) error
+	parseObject = func(prefix string) error {
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := prefix + tok.(string)
+
+			tok, err = dec.Token()
+			if err != nil {
+				return err
+			}
+
+			switch v := tok.(type) {
+			case string:
+				entries = append(entries, entry{key: key, value: v})
+			case json.Delim:
+				if v != '{' {
+					return fmt.Errorf("unsupported value for %q: only strings and objects are allowed", key)
+				}
+				if err = parseObject(key + "."); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unsupported value for %q: only strings and objects are allowed", key)
+			}
+		}
+
+		// Read the closing delimiter
+		_, err := dec.Token()
+		return err
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, &ParseError{Msg: err.Error()}
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, &ParseError{Msg: "expected a JSON object"}
+	}
+	if err = parseObject(""); err != nil {
+		return nil, &ParseError{Msg: err.Error()}
+	}
+
+	tag, err := language.Parse(strings.Replace(lang, "_", "-", -1))
+	if err != nil {
+		tag = language.Und
+	}
+	forms, selectForm := cldrPlurals(tag)
+
+	c := New()
+	c.SetHeader("Language", lang)
+	c.SetPluralFunc(len(forms), selectForm)
+
+	for _, e := range entries {
+		id, idx := e.key, -1
+		for _, pf := range jsonPluralForms {
+			if strings.HasSuffix(e.key, pf.suffix) {
+				id = strings.TrimSuffix(e.key, pf.suffix)
+				idx = indexOfForm(forms, pf.form)
+				break
+			}
+		}
+
+		if idx < 0 && id != e.key {
+			// The plural form is not used by this language
+			continue
+		}
+
+		if idx < 0 {
+			// Plural messages are used for both singular and plural lookups, so they take precedence
+			if m := c.Lookup("", id); m == nil || m.IDPlural == "" {
+				c.Add(&Message{ID: id, Str: []string{e.value}})
+			}
+			continue
+		}
+
+		m := c.Lookup("", id)
+		if m == nil || m.IDPlural == "" {
+			m = &Message{ID: id, IDPlural: id, Str: make([]string, len(forms))}
+			c.Add(m)
+		}
+		m.Str[idx] = e.value
+	}
+
+	// Make the messages available with a context as well, by splitting the msgid on each '_'.
+	// Existing messages are never replaced
+	for _, m := range c.Messages {
+		for idx := 1; idx < len(m.ID)-1; idx++ {
+			if m.ID[idx] != '_' {
+				continue
+			}
+
+			key := messageKey{context: m.ID[idx+1:], id: m.ID[:idx]}
+			if _, ok := c.index[key]; !ok {
+				c.index[key] = m
+			}
+		}
+	}
+	return c, nil
+}
+
+// cldrPlurals returns the CLDR plural forms used for integers in a language, and a function that selects the
+// index of the form to use for n
+func cldrPlurals(tag language.Tag) ([]plural.Form, func(n int) int) {
+	match := func(n int) plural.Form {
+		if n < 0 {
+			n = -n
+		}
+		return plural.Cardinal.MatchPlural(tag, n%10000000, 0, 0, 0, 0)
+	}
+
+	// The rules for all languages repeat within the first 1000 numbers (e.g. n%100 or n%10)
+	used := map[plural.Form]bool{}
+	for n := 0; n < 1000; n++ {
+		used[match(n)] = true
+	}
+
+	var forms []plural.Form
+	for _, pf := range jsonPluralForms {
+		if used[pf.form] {
+			forms = append(forms, pf.form)
+		}
+	}
+
+	return forms, func(n int) int {
+		return indexOfForm(forms, match(n))
+	}
+}
+
+func indexOfForm(forms []plural.Form, form plural.Form) int {
+	for k, f := range forms {
+		if f == form {
+			return k
+		}
+	}
+	return -1
+}
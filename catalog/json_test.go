@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testJSON = `{
+	"Hello world!": "Hej världen!",
+	"nav": {
+		"home": "Hem",
+		"about": {"title": "Om oss"}
+	},
+	"file_one": "{{ count }} fil",
+	"file_other": "{{ count }} filer",
+	"file_many": "Not used in Swedish",
+	"friend": "En vän",
+	"friend_male": "En manlig vän",
+	"friend_male_one": "En manlig vän",
+	"friend_male_other": "{{ count }} manliga vänner",
+	"sign_in": "Logga in",
+	"empty": ""
+}`
+
+func TestParseJSON(t *testing.T) {
+	cat, err := ParseJSON([]byte(testJSON), "sv_SE")
+	require.Nil(t, err)
+	require.Equal(t, "sv_SE", cat.Header("Language"))
+	require.Equal(t, 2, cat.NPlurals())
+
+	type T struct {
+		context  string
+		id       string
+		plural   bool
+		n        int
+		expected string
+		found    bool
+	}
+
+	tests := []T{
+		{id: "Hello world!", expected: "Hej världen!", found: true},
+		{id: "nav.home", expected: "Hem", found: true},
+		{id: "nav.about.title", expected: "Om oss", found: true},
+		{id: "nav", found: false},
+		{id: "file", plural: true, n: 1, expected: "{{ count }} fil", found: true},
+		{id: "file", plural: true, n: 0, expected: "{{ count }} filer", found: true},
+		{id: "file", plural: true, n: 5, expected: "{{ count }} filer", found: true},
+		{id: "file_many", found: false},
+		{id: "friend", expected: "En vän", found: true},
+		{id: "friend_male", plural: true, n: 1, expected: "En manlig vän", found: true},
+		{context: "male", id: "friend", plural: true, n: 1, expected: "En manlig vän", found: true},
+		{context: "male", id: "friend", plural: true, n: 3, expected: "{{ count }} manliga vänner", found: true},
+		{context: "female", id: "friend", found: false},
+		{id: "sign_in", expected: "Logga in", found: true},
+		{context: "in", id: "sign", expected: "Logga in", found: true},
+		{id: "empty", found: false},
+	}
+
+	for k, tst := range tests {
+		var tr string
+		var ok bool
+		if tst.plural {
+			tr, ok = cat.PluralTranslation(tst.context, tst.id, tst.n)
+		} else {
+			tr, ok = cat.Translation(tst.context, tst.id)
+		}
+		require.Equalf(t, tst.found, ok, "test: %d, id: %s", k, tst.id)
+		require.Equalf(t, tst.expected, tr, "test: %d, id: %s", k, tst.id)
+	}
+
+	// Context aliases are not listed as separate messages,
+	// and 'friend_male' is replaced by the plural message with the same key
+	require.Equal(t, Stats{Total: 8, Translated: 7, Untranslated: 1}, cat.Stats())
+}
+
+func TestParseJSON_Plurals(t *testing.T) {
+	cat, err := ParseJSON([]byte(`{
+		"apple_one": "{{ count }} яблоко",
+		"apple_few": "{{ count }} яблока",
+		"apple_many": "{{ count }} яблок",
+		"apple_other": "{{ count }} яблока (other)"
+	}`), "ru")
+	require.Nil(t, err)
+
+	// Russian only uses 'other' for fractions
+	require.Equal(t, 3, cat.NPlurals())
+
+	expected := map[int]string{
+		1:  "{{ count }} яблоко",
+		2:  "{{ count }} яблока",
+		5:  "{{ count }} яблок",
+		11: "{{ count }} яблок",
+		21: "{{ count }} яблоко",
+		22: "{{ count }} яблока",
+	}
+	for n, str := range expected {
+		tr, ok := cat.PluralTranslation("", "apple", n)
+		require.Truef(t, ok, "n: %d", n)
+		require.Equalf(t, str, tr, "n: %d", n)
+	}
+
+	cat, err = ParseJSON([]byte(`{"apple_other": "{{ count }} りんご"}`), "ja")
+	require.Nil(t, err)
+	require.Equal(t, 1, cat.NPlurals())
+	tr, ok := cat.PluralTranslation("", "apple", 1)
+	require.True(t, ok)
+	require.Equal(t, "{{ count }} りんご", tr)
+}
+
+func TestParseJSON_Errors(t *testing.T) {
+	for k, data := range []string{
+		`["a", "b"]`,
+		`{"a": 1}`,
+		`{"a": ["b"]}`,
+		`{"a": "b"`,
+		`{"a": {"b": true}}`,
+	} {
+		_, err := ParseJSON([]byte(data), "sv_SE")
+		require.NotNilf(t, err, "test: %d, data: %s", k, data)
+		_, ok := err.(*ParseError)
+		require.Truef(t, ok, "test: %d, data: %s", k, data)
+	}
+}
@@ -0,0 +1,226 @@
+package catalog
+
+// fuzzyThreshold is the minimum similarity for an old translation to be reused for a new message, same as msgmerge
+const fuzzyThreshold = 0.6
+
+// Merge updates the translations in po with the messages in the template pot, in the same way as GNU msgmerge:
+//
+//   - Messages in pot keep their translation from po
+//   - Messages that are not found in po are matched against similar messages, and marked as fuzzy if a match is found
+//   - Messages in po that are not found in pot are marked as obsolete
+//
+// The header and translator comments of po are kept. References, extracted comments and format flags are
+// taken from pot. The returned catalog contains the messages in the same order as pot, followed by obsolete messages.
+func Merge(po, pot *Catalog) *Catalog {
+	result := New()
+
+	header := po.HeaderMessage()
+	if header == nil {
+		header = pot.HeaderMessage()
+	}
+	if header != nil {
+		header = copyMessage(header)
+		result.Add(header)
+		if date := pot.Header("POT-Creation-Date"); date != "" {
+			result.SetHeader("POT-Creation-Date", date)
+		}
+	}
+
+	nplurals := po.NPlurals()
+	if nplurals <= 0 {
+		nplurals = 2
+	}
+
+	// Obsolete messages can be revived if they are used again
+	obsolete := map[messageKey]*Message{}
+	for _, m := range po.Messages {
+		if m.Obsolete {
+			obsolete[messageKey{context: m.Context, id: m.ID}] = m
+		}
+	}
+
+	used := map[*Message]bool{}
+	for _, m := range pot.Messages {
+		if m.Obsolete || (m.ID == "" && m.Context == "") {
+			continue
+		}
+
+		key := messageKey{context: m.Context, id: m.ID}
+		old := po.Lookup(m.Context, m.ID)
+		if old == nil {
+			old = obsolete[key]
+		}
+
+		msg := copyMessage(m)
+		msg.Flags = withoutFlag(msg.Flags, "fuzzy")
+		msg.Str = nil
+
+		switch {
+		case old != nil:
+			used[old] = true
+			msg.TranslatorComments = old.TranslatorComments
+			msg.Str = old.Str
+			if old.IsFuzzy() {
+				msg.Flags = append(msg.Flags, "fuzzy")
+				msg.PreviousContext = old.PreviousContext
+				msg.PreviousID = old.PreviousID
+				msg.PreviousIDPlural = old.PreviousIDPlural
+			}
+
+			// The message has changed to or from a plural message
+			if (m.IDPlural == "") != (old.IDPlural == "") {
+				msg.Flags = withFlag(msg.Flags, "fuzzy")
+				msg.PreviousIDPlural = old.IDPlural
+			}
+
+		default:
+			if similar := findSimilar(po, m); similar != nil {
+				msg.TranslatorComments = similar.TranslatorComments
+				msg.Str = similar.Str
+				msg.Flags = append(msg.Flags, "fuzzy")
+				msg.PreviousContext = similar.Context
+				msg.PreviousID = similar.ID
+				msg.PreviousIDPlural = similar.IDPlural
+			}
+		}
+
+		msg.Str = resizeStr(msg.Str, m.IDPlural != "", nplurals)
+		result.Add(msg)
+	}
+
+	// Translated messages that are not used anymore are kept as obsolete messages
+	for _, m := range po.Messages {
+		if used[m] || (m.ID == "" && m.Context == "") || (!m.Obsolete && !hasTranslation(m)) {
+			continue
+		}
+
+		msg := copyMessage(m)
+		msg.Obsolete = true
+		msg.References = nil
+		result.Add(msg)
+	}
+	return result
+}
+
+// findSimilar returns the translated message in po that is most similar to m,
+// or nil if no message is similar enough
+func findSimilar(po *Catalog, m *Message) *Message {
+	var best *Message
+	bestScore := fuzzyThreshold
+
+	for _, candidate := range po.Messages {
+		if candidate.Obsolete || candidate.ID == "" || !hasTranslation(candidate) {
+			continue
+		}
+
+		score := similarity(m.Context+"\x04"+m.ID, candidate.Context+"\x04"+candidate.ID, bestScore)
+		if score > bestScore || (best == nil && score >= bestScore) {
+			best = candidate
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// similarity returns how similar two strings are, from 0 to 1, calculated as 2*LCS/(len(a)+len(b)).
+// Since this is expensive for long strings, 0 is returned without calculating the similarity if it's
+// certain to be below min.
+func similarity(a, b string, min float64) float64 {
+	ra, rb := []rune(a), []rune(b)
+	total := len(ra) + len(rb)
+	if total == 0 {
+		return 1
+	}
+
+	// The common subsequence can't be longer than the shortest string
+	shortest := len(ra)
+	if len(rb) < shortest {
+		shortest = len(rb)
+	}
+	if float64(2*shortest)/float64(total) < min {
+		return 0
+	}
+
+	// Nor contain more of each character than both strings
+	counts := map[rune]int{}
+	for _, r := range ra {
+		counts[r]++
+	}
+	common := 0
+	for _, r := range rb {
+		if counts[r] > 0 {
+			counts[r]--
+			common++
+		}
+	}
+	if float64(2*common)/float64(total) < min {
+		return 0
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			switch {
+			case ra[i-1] == rb[j-1]:
+				cur[j] = prev[j-1] + 1
+			case prev[j] > cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return float64(2*prev[len(rb)]) / float64(total)
+}
+
+// resizeStr returns translations with one entry for non-plural messages, and nplurals entries for plural messages
+func resizeStr(str []string, plural bool, nplurals int) []string {
+	n := 1
+	if plural {
+		n = nplurals
+	}
+
+	resized := make([]string, n)
+	copy(resized, str)
+	return resized
+}
+
+func hasTranslation(m *Message) bool {
+	for _, str := range m.Str {
+		if str != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func copyMessage(m *Message) *Message {
+	msg := *m
+	msg.Str = append([]string(nil), m.Str...)
+	msg.TranslatorComments = append([]string(nil), m.TranslatorComments...)
+	msg.ExtractedComments = append([]string(nil), m.ExtractedComments...)
+	msg.References = append([]string(nil), m.References...)
+	msg.Flags = append([]string(nil), m.Flags...)
+	return &msg
+}
+
+func withFlag(flags []string, flag string) []string {
+	for _, f := range flags {
+		if f == flag {
+			return flags
+		}
+	}
+	return append(flags, flag)
+}
+
+func withoutFlag(flags []string, flag string) []string {
+	var result []string
+	for _, f := range flags {
+		if f != flag {
+			result = append(result, f)
+		}
+	}
+	return result
+}
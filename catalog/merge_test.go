@@ -0,0 +1,170 @@
+package catalog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testMergePo = `# Swedish translation
+msgid ""
+msgstr ""
+"Language: sv_SE\n"
+"POT-Creation-Date: 2020-01-01 00:00+0000\n"
+"Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+# Translator comment
+#: old.html:1
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+msgid "Welcome to our website"
+msgstr "Välkommen till vår webbplats"
+
+msgid "Removed"
+msgstr "Borttagen"
+
+msgid "Removed and untranslated"
+msgstr ""
+
+msgid "One file"
+msgstr "En fil"
+
+#~ msgid "Revived"
+#~ msgstr "Återupplivad"
+`
+
+const testMergePot = `msgid ""
+msgstr ""
+"POT-Creation-Date: 2021-02-03 04:05+0000\n"
+"Plural-Forms: nplurals=INTEGER; plural=EXPRESSION;\n"
+
+#: index.html:3
+msgid "Hello world!"
+msgstr ""
+
+#: index.html:4
+msgid "Welcome to our web site"
+msgstr ""
+
+msgid "One file"
+msgid_plural "Many files"
+msgstr[0] ""
+msgstr[1] ""
+
+msgid "Revived"
+msgstr ""
+
+msgid "Something completely different"
+msgstr ""
+`
+
+func TestMerge(t *testing.T) {
+	po, err := ParsePo([]byte(testMergePo))
+	require.Nil(t, err)
+	pot, err := ParsePo([]byte(testMergePot))
+	require.Nil(t, err)
+
+	cat := Merge(po, pot)
+
+	header := cat.HeaderMessage()
+	require.NotNil(t, header)
+	require.Equal(t, []string{"Swedish translation"}, header.TranslatorComments)
+	require.Equal(t, "sv_SE", cat.Header("Language"))
+	require.Equal(t, "2021-02-03 04:05+0000", cat.Header("POT-Creation-Date"))
+	require.Equal(t, 2, cat.NPlurals())
+
+	var ids []string
+	for _, m := range cat.Messages {
+		if m.Obsolete {
+			ids = append(ids, "#~ "+m.ID)
+			continue
+		}
+		ids = append(ids, m.ID)
+	}
+	require.Equal(t, []string{"", "Hello world!", "Welcome to our web site", "One file", "Revived",
+		"Something completely different", "#~ Welcome to our website", "#~ Removed"}, ids)
+
+	m := cat.Lookup("", "Hello world!")
+	require.Equal(t, []string{"Hej världen!"}, m.Str)
+	require.Equal(t, []string{"Translator comment"}, m.TranslatorComments)
+	require.Equal(t, []string{"index.html:3"}, m.References)
+	require.False(t, m.IsFuzzy())
+
+	m = cat.Lookup("", "Welcome to our web site")
+	require.Equal(t, []string{"Välkommen till vår webbplats"}, m.Str)
+	require.True(t, m.IsFuzzy())
+	require.Equal(t, "Welcome to our website", m.PreviousID)
+
+	m = cat.Lookup("", "One file")
+	require.Equal(t, []string{"En fil", ""}, m.Str)
+	require.True(t, m.IsFuzzy())
+
+	m = cat.Lookup("", "Revived")
+	require.Equal(t, []string{"Återupplivad"}, m.Str)
+	require.False(t, m.IsFuzzy())
+
+	m = cat.Lookup("", "Something completely different")
+	require.Equal(t, []string{""}, m.Str)
+	require.False(t, m.IsFuzzy())
+
+	// Merging again with the same template doesn't change anything
+	again := Merge(cat, pot)
+	require.Equal(t, cat.Messages, again.Messages)
+}
+
+// Fuzzy messages that become obsolete keep their previous values, and must still be readable after they are written
+func TestMerge_RoundTrip(t *testing.T) {
+	po, err := ParsePo([]byte(testMergePo))
+	require.Nil(t, err)
+	pot, err := ParsePo([]byte(testMergePot))
+	require.Nil(t, err)
+	empty, err := ParsePo([]byte("msgid \"\"\nmsgstr \"\"\n"))
+	require.Nil(t, err)
+
+	// The fuzzy 'Welcome to our web site' is removed from the template
+	cat := Merge(Merge(po, pot), empty)
+	var buf bytes.Buffer
+	require.Nil(t, WritePo(&buf, cat))
+	require.Contains(t, buf.String(), "#~| msgid \"Welcome to our website\"\n")
+
+	parsed, err := ParsePo(buf.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, cat.Messages, parsed.Messages)
+
+	again := Merge(parsed, pot)
+	m := again.Lookup("", "Welcome to our web site")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"Välkommen till vår webbplats"}, m.Str)
+
+	// Previous values written by other tools are accepted as well
+	parsed, err = ParsePo([]byte("msgid \"a\"\nmsgstr \"b\"\n\n#, fuzzy\n#~ #| msgid \"c\"\n#~ msgid \"d\"\n#~ msgstr \"e\"\n"))
+	require.Nil(t, err)
+	require.Len(t, parsed.Messages, 2)
+	require.Equal(t, "", parsed.Messages[0].PreviousID)
+	require.Equal(t, "c", parsed.Messages[1].PreviousID)
+	require.True(t, parsed.Messages[1].Obsolete)
+}
+
+func TestSimilarity(t *testing.T) {
+	type T struct {
+		a, b     string
+		expected float64
+	}
+
+	tests := []T{
+		{a: "", b: "", expected: 1},
+		{a: "abc", b: "abc", expected: 1},
+		{a: "abc", b: "xyz", expected: 0},
+		{a: "abcd", b: "abce", expected: 0.75},
+		{a: "åäö", b: "åäo", expected: 2.0 * 2 / 6},
+	}
+
+	for k, tst := range tests {
+		require.InDeltaf(t, tst.expected, similarity(tst.a, tst.b, 0), 0.0001, "test: %d, a: %s, b: %s", k, tst.a, tst.b)
+	}
+
+	// Strings that can't reach the minimum similarity are skipped
+	require.Equal(t, 0.0, similarity("a", "abcdef", 0.6))
+}
@@ -0,0 +1,217 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	moMagicLittleEndian = 0x950412de
+	moMagicBigEndian    = 0xde120495
+
+	// Separates the context from the msgid in .mo-files
+	contextSeparator = "\x04"
+	// Separates the msgid from msgid_plural, and the different plural forms in .mo-files
+	pluralSeparator = "\x00"
+)
+
+// ParseMo parses the contents of a .mo-file
+func ParseMo(data []byte) (*Catalog, error) {
+	if len(data) < 28 {
+		return nil, &ParseError{Msg: "file is too short"}
+	}
+
+	var bo binary.ByteOrder
+	switch binary.LittleEndian.Uint32(data) {
+	case moMagicLittleEndian:
+		bo = binary.LittleEndian
+	case moMagicBigEndian:
+		bo = binary.BigEndian
+	default:
+		return nil, &ParseError{Msg: "invalid magic number"}
+	}
+
+	if major := bo.Uint32(data[4:]) >> 16; major > 1 {
+		return nil, &ParseError{Msg: "unsupported file format revision"}
+	}
+
+	count := int(bo.Uint32(data[8:]))
+	idTable := int(bo.Uint32(data[12:]))
+	strTable := int(bo.Uint32(data[16:]))
+
+	// readString reads the n:th string from a string table
+	readString := func(table, n int) (string, bool) {
+		pos := table + n*8
+		if pos < 0 || pos+8 > len(data) {
+			return "", false
+		}
+
+		length := int(bo.Uint32(data[pos:]))
+		offset := int(bo.Uint32(data[pos+4:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return "", false
+		}
+		return string(data[offset : offset+length]), true
+	}
+
+	cat := New()
+	for i := 0; i < count; i++ {
+		id, ok := readString(idTable, i)
+		if !ok {
+			return nil, &ParseError{Msg: "invalid offset in msgid table"}
+		}
+		str, ok := readString(strTable, i)
+		if !ok {
+			return nil, &ParseError{Msg: "invalid offset in msgstr table"}
+		}
+
+		m := &Message{}
+		if idx := strings.Index(id, contextSeparator); idx >= 0 {
+			m.Context, id = id[:idx], id[idx+1:]
+		}
+
+		if idx := strings.Index(id, pluralSeparator); idx >= 0 {
+			m.ID, m.IDPlural = id[:idx], id[idx+1:]
+			m.Str = strings.Split(str, pluralSeparator)
+		} else {
+			m.ID = id
+			m.Str = []string{str}
+		}
+		cat.Add(m)
+	}
+	return cat, nil
+}
+
+// MoOption is used to change how catalogs are written by WriteMo
+type MoOption func(*moOptions)
+
+type moOptions struct {
+	fuzzy bool
+}
+
+// WithFuzzyMessages includes messages marked as fuzzy when writing .mo-files. By default, they are skipped
+func WithFuzzyMessages() MoOption {
+	return func(o *moOptions) {
+		o.fuzzy = true
+	}
+}
+
+// WriteMo writes a catalog as a .mo-file, including a hash table for faster lookups, in the same way as GNU msgfmt.
+// Untranslated and obsolete messages are skipped. The header is always included, since it contains the plural forms.
+func WriteMo(w io.Writer, c *Catalog, options ...MoOption) error {
+	var o moOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	type entry struct {
+		id  string
+		str string
+	}
+
+	var entries []entry
+	for _, m := range c.Messages {
+		isHeader := m.ID == "" && m.Context == ""
+		if m.Obsolete || !hasTranslation(m) || (m.IsFuzzy() && !o.fuzzy && !isHeader) {
+			continue
+		}
+
+		id := m.ID
+		if m.IDPlural != "" {
+			id += pluralSeparator + m.IDPlural
+		}
+		if m.Context != "" {
+			id = m.Context + contextSeparator + id
+		}
+		entries = append(entries, entry{id: id, str: strings.Join(m.Str, pluralSeparator)})
+	}
+
+	// The messages must be sorted by msgid
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].id < entries[j].id
+	})
+
+	n := len(entries)
+	hashSize := moHashSize(n)
+	idTable := 28
+	strTable := idTable + n*8
+	hashTable := strTable + n*8
+	offset := hashTable + hashSize*4
+
+	buf := make([]byte, offset)
+	bo := binary.LittleEndian
+	bo.PutUint32(buf[0:], moMagicLittleEndian)
+	bo.PutUint32(buf[8:], uint32(n))
+	bo.PutUint32(buf[12:], uint32(idTable))
+	bo.PutUint32(buf[16:], uint32(strTable))
+	bo.PutUint32(buf[20:], uint32(hashSize))
+	bo.PutUint32(buf[24:], uint32(hashTable))
+
+	addString := func(pos int, s string) {
+		bo.PutUint32(buf[pos:], uint32(len(s)))
+		bo.PutUint32(buf[pos+4:], uint32(len(buf)))
+		buf = append(buf, s...)
+		buf = append(buf, 0)
+	}
+
+	for i, e := range entries {
+		addString(idTable+i*8, e.id)
+	}
+	for i, e := range entries {
+		addString(strTable+i*8, e.str)
+	}
+
+	// Use open addressing with double hashing, as described in the GNU gettext manual
+	for i, e := range entries {
+		h := hashString(e.id)
+		idx := h % uint32(hashSize)
+		incr := 1 + h%uint32(hashSize-2)
+		for bo.Uint32(buf[hashTable+int(idx)*4:]) != 0 {
+			idx += incr
+			if idx >= uint32(hashSize) {
+				idx -= uint32(hashSize)
+			}
+		}
+		bo.PutUint32(buf[hashTable+int(idx)*4:], uint32(i+1))
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// moHashSize returns the size of the hash table for n messages, which is the smallest prime not less than 4n/3
+func moHashSize(n int) int {
+	size := n * 4 / 3
+	if size < 3 {
+		size = 3
+	}
+
+	for ; ; size++ {
+		prime := true
+		for d := 2; d*d <= size; d++ {
+			if size%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return size
+		}
+	}
+}
+
+// hashString is the 'hashpjw' function used for the hash table in .mo-files.
+// Like in GNU gettext, the string ends at the first NUL, so plural messages are hashed by their singular msgid only
+func hashString(s string) uint32 {
+	var h uint32
+	for i := 0; i < len(s) && s[i] != 0; i++ {
+		h = h<<4 + uint32(s[i])
+		if g := h & 0xf0000000; g != 0 {
+			h ^= g >> 24
+			h ^= g
+		}
+	}
+	return h
+}
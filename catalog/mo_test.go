@@ -0,0 +1,152 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildMo creates the contents of a little endian .mo-file without a hash table
+func buildMo(ids, strs []string) []byte {
+	n := len(ids)
+	idTable := 28
+	strTable := idTable + n*8
+	dataOffset := strTable + n*8
+
+	buf := make([]byte, dataOffset)
+	binary.LittleEndian.PutUint32(buf[0:], moMagicLittleEndian)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(n))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(idTable))
+	binary.LittleEndian.PutUint32(buf[16:], uint32(strTable))
+
+	for k, table := range [][]string{ids, strs} {
+		tableOffset := idTable
+		if k == 1 {
+			tableOffset = strTable
+		}
+		for i, s := range table {
+			binary.LittleEndian.PutUint32(buf[tableOffset+i*8:], uint32(len(s)))
+			binary.LittleEndian.PutUint32(buf[tableOffset+i*8+4:], uint32(len(buf)))
+			buf = append(buf, s...)
+			buf = append(buf, 0)
+		}
+	}
+	return buf
+}
+
+func TestParseMo(t *testing.T) {
+	data := buildMo(
+		[]string{"", "Hello world!", "month\x04May", "One file\x00Many files"},
+		[]string{"Language: sv_SE\nPlural-Forms: nplurals=2; plural=n != 1;\n", "Hej världen!", "Maj", "En fil\x00Flera filer"},
+	)
+
+	cat, err := ParseMo(data)
+	require.Nil(t, err)
+	require.Equal(t, "sv_SE", cat.Header("Language"))
+	require.Equal(t, 2, cat.NPlurals())
+
+	tr, ok := cat.Translation("", "Hello world!")
+	require.True(t, ok)
+	require.Equal(t, "Hej världen!", tr)
+
+	tr, ok = cat.Translation("month", "May")
+	require.True(t, ok)
+	require.Equal(t, "Maj", tr)
+
+	m := cat.Lookup("", "One file")
+	require.NotNil(t, m)
+	require.Equal(t, "Many files", m.IDPlural)
+	require.Equal(t, []string{"En fil", "Flera filer"}, m.Str)
+
+	_, err = ParseMo([]byte("not a mo-file"))
+	require.NotNil(t, err)
+
+	_, err = ParseMo(data[:40])
+	require.NotNil(t, err)
+}
+
+func TestWriteMo(t *testing.T) {
+	po, err := ParsePo([]byte(testPo))
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	require.Nil(t, WriteMo(&buf, po))
+	data := buf.Bytes()
+
+	cat, err := ParseMo(data)
+	require.Nil(t, err)
+	require.Len(t, cat.Messages, 4)
+	require.Equal(t, "sv_SE", cat.Header("Language"))
+
+	tr, ok := cat.Translation("", "Hello world!")
+	require.True(t, ok)
+	require.Equal(t, "Hej världen!", tr)
+
+	tr, ok = cat.Translation("month", "May")
+	require.True(t, ok)
+	require.Equal(t, "Maj", tr)
+
+	tr, ok = cat.PluralTranslation("", "One file", 2)
+	require.True(t, ok)
+	require.Equal(t, "Flera \"filer\"\n", tr)
+
+	// Fuzzy, untranslated and obsolete messages are skipped
+	require.Nil(t, cat.Lookup("", "Multiline"))
+	require.Nil(t, cat.Lookup("", "Untranslated"))
+	require.Nil(t, cat.Lookup("", "Obsolete"))
+
+	// All messages should be found in the hash table in the same way as by GNU gettext, which hashes the msgid
+	// given to gettext/ngettext (i.e. the singular form), and compares it with the stored msgid up to the first NUL
+	count := int(binary.LittleEndian.Uint32(data[8:]))
+	hashSize := binary.LittleEndian.Uint32(data[20:])
+	hashTable := int(binary.LittleEndian.Uint32(data[24:]))
+	require.Equal(t, uint32(5), hashSize)
+
+	for _, key := range []string{"", "Hello world!", "month\x04May", "One file"} {
+		h := hashString(key)
+		idx := h % hashSize
+		found := false
+		for {
+			n := int(binary.LittleEndian.Uint32(data[hashTable+int(idx)*4:]))
+			if n == 0 {
+				break
+			}
+			require.LessOrEqual(t, n, count)
+
+			pos := int(binary.LittleEndian.Uint32(data[12:])) + (n-1)*8
+			length := int(binary.LittleEndian.Uint32(data[pos:]))
+			offset := int(binary.LittleEndian.Uint32(data[pos+4:]))
+			stored := data[offset : offset+length]
+			if end := bytes.IndexByte(stored, 0); end >= 0 {
+				stored = stored[:end]
+			}
+			if length >= len(key) && string(stored) == key {
+				found = true
+				break
+			}
+			idx = (idx + 1 + h%(hashSize-2)) % hashSize
+		}
+		require.Truef(t, found, "key: %q", key)
+	}
+
+	buf.Reset()
+	require.Nil(t, WriteMo(&buf, po, WithFuzzyMessages()))
+	cat, err = ParseMo(buf.Bytes())
+	require.Nil(t, err)
+	tr, ok = cat.Translation("", "Multiline")
+	require.True(t, ok)
+	require.Equal(t, "Flerrader", tr)
+}
+
+func TestHashString(t *testing.T) {
+	require.Equal(t, uint32(0), hashString(""))
+	require.Equal(t, uint32(0x61), hashString("a"))
+	require.Equal(t, uint32(0x672), hashString("ab"))
+	// The high bits are folded back when the string is longer than 7 bytes
+	require.Equal(t, uint32(0x34ac171), hashString("Hello world!"))
+	require.Equal(t, uint32(0x5a907e9), hashString("month\x04May"))
+	// Only the singular msgid of plural messages is hashed
+	require.Equal(t, hashString("One file"), hashString("One file\x00Many files"))
+}
@@ -0,0 +1,499 @@
+package catalog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes a syntax error in a catalog
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return e.Msg
+}
+
+type poState int
+
+const (
+	poStateNone poState = iota
+	poStateContext
+	poStateID
+	poStateIDPlural
+	poStateStr
+)
+
+type poParser struct {
+	cat *Catalog
+
+	msg       *Message
+	hasID     bool
+	state     poState
+	strIdx    int
+	prevState poState
+	line      int
+}
+
+// ParsePo parses the contents of a .po-file.
+// If a message is defined more than once, the last definition is used, like gettext readers such as gotext do
+func ParsePo(data []byte) (*Catalog, error) {
+	p := &poParser{cat: New(), msg: &Message{}}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		p.line++
+		if err := p.parseLine(strings.TrimSpace(line)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.flush(); err != nil {
+		return nil, err
+	}
+	return p.cat, nil
+}
+
+func (p *poParser) error(format string, args ...interface{}) error {
+	return &ParseError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *poParser) parseLine(line string) error {
+	switch {
+	case line == "":
+		return nil
+
+	case strings.HasPrefix(line, "#~"):
+		line = strings.TrimSpace(line[2:])
+
+		// Previous values of obsolete messages are written as '#~| msgid', but '#~ #| msgid' is accepted as well
+		if strings.HasPrefix(line, "|") || strings.HasPrefix(line, "#|") {
+			if p.state == poStateStr {
+				if err := p.flush(); err != nil {
+					return err
+				}
+			}
+			return p.parsePrevious(strings.TrimSpace(line[strings.Index(line, "|")+1:]))
+		}
+		if line == "" {
+			return nil
+		}
+		if p.state == poStateStr && !p.msg.Obsolete {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		p.msg.Obsolete = true
+		return p.parseKeyword(line)
+
+	case strings.HasPrefix(line, "#"):
+		// Comments belong to the next message
+		if p.state == poStateStr {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		return p.parseComment(line)
+	}
+	return p.parseKeyword(line)
+}
+
+func (p *poParser) parseComment(line string) error {
+	if len(line) == 1 {
+		p.msg.TranslatorComments = append(p.msg.TranslatorComments, "")
+		return nil
+	}
+
+	switch line[1] {
+	case '.':
+		p.msg.ExtractedComments = append(p.msg.ExtractedComments, strings.TrimSpace(line[2:]))
+	case ':':
+		p.msg.References = append(p.msg.References, strings.Fields(line[2:])...)
+	case ',':
+		for _, flag := range strings.Split(line[2:], ",") {
+			if flag = strings.TrimSpace(flag); flag != "" {
+				p.msg.Flags = append(p.msg.Flags, flag)
+			}
+		}
+	case '|':
+		return p.parsePrevious(strings.TrimSpace(line[2:]))
+	default:
+		comment := line[1:]
+		if strings.HasPrefix(comment, " ") {
+			comment = comment[1:]
+		}
+		p.msg.TranslatorComments = append(p.msg.TranslatorComments, comment)
+	}
+	return nil
+}
+
+// parsePrevious parses the previous values of a message (lines starting with '#|')
+func (p *poParser) parsePrevious(line string) error {
+	var field *string
+	switch {
+	case strings.HasPrefix(line, "msgctxt"):
+		p.prevState = poStateContext
+		field, line = &p.msg.PreviousContext, line[len("msgctxt"):]
+	case strings.HasPrefix(line, "msgid_plural"):
+		p.prevState = poStateIDPlural
+		field, line = &p.msg.PreviousIDPlural, line[len("msgid_plural"):]
+	case strings.HasPrefix(line, "msgid"):
+		p.prevState = poStateID
+		field, line = &p.msg.PreviousID, line[len("msgid"):]
+	case strings.HasPrefix(line, `"`):
+		switch p.prevState {
+		case poStateContext:
+			field = &p.msg.PreviousContext
+		case poStateID:
+			field = &p.msg.PreviousID
+		case poStateIDPlural:
+			field = &p.msg.PreviousIDPlural
+		default:
+			return p.error("unexpected string")
+		}
+	default:
+		return p.error("unknown keyword in previous value")
+	}
+
+	str, err := p.unquote(line)
+	if err != nil {
+		return err
+	}
+	*field += str
+	return nil
+}
+
+func (p *poParser) parseKeyword(line string) error {
+	switch {
+	case strings.HasPrefix(line, `"`):
+		str, err := p.unquote(line)
+		if err != nil {
+			return err
+		}
+
+		switch p.state {
+		case poStateContext:
+			p.msg.Context += str
+		case poStateID:
+			p.msg.ID += str
+		case poStateIDPlural:
+			p.msg.IDPlural += str
+		case poStateStr:
+			p.msg.Str[p.strIdx] += str
+		default:
+			return p.error("unexpected string")
+		}
+
+	case strings.HasPrefix(line, "msgctxt"):
+		if p.state == poStateStr {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		} else if p.state != poStateNone {
+			return p.error("unexpected 'msgctxt'")
+		}
+
+		str, err := p.unquote(line[len("msgctxt"):])
+		if err != nil {
+			return err
+		}
+		p.msg.Context = str
+		p.state = poStateContext
+
+	case strings.HasPrefix(line, "msgid_plural"):
+		if p.state != poStateID {
+			return p.error("'msgid_plural' must follow 'msgid'")
+		}
+
+		str, err := p.unquote(line[len("msgid_plural"):])
+		if err != nil {
+			return err
+		}
+		p.msg.IDPlural = str
+		p.state = poStateIDPlural
+
+	case strings.HasPrefix(line, "msgid"):
+		if p.state == poStateStr {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		} else if p.state != poStateNone && p.state != poStateContext {
+			return p.error("unexpected 'msgid'")
+		}
+
+		str, err := p.unquote(line[len("msgid"):])
+		if err != nil {
+			return err
+		}
+		p.msg.ID = str
+		p.hasID = true
+		p.state = poStateID
+
+	case strings.HasPrefix(line, "msgstr["):
+		if p.state != poStateIDPlural && p.state != poStateStr {
+			return p.error("'msgstr[]' must follow 'msgid_plural'")
+		}
+
+		end := strings.Index(line, "]")
+		if end < 0 {
+			return p.error("missing ']' in 'msgstr[]'")
+		}
+
+		idx, err := strconv.Atoi(line[len("msgstr["):end])
+		if err != nil || idx != len(p.msg.Str) {
+			return p.error("invalid index in 'msgstr[]'")
+		}
+
+		str, err := p.unquote(line[end+1:])
+		if err != nil {
+			return err
+		}
+		p.msg.Str = append(p.msg.Str, str)
+		p.strIdx = idx
+		p.state = poStateStr
+
+	case strings.HasPrefix(line, "msgstr"):
+		if p.state != poStateID {
+			if p.state == poStateIDPlural {
+				return p.error("plural messages must use 'msgstr[]'")
+			}
+			return p.error("'msgstr' must follow 'msgid'")
+		}
+
+		str, err := p.unquote(line[len("msgstr"):])
+		if err != nil {
+			return err
+		}
+		p.msg.Str = []string{str}
+		p.strIdx = 0
+		p.state = poStateStr
+
+	default:
+		return p.error("unknown keyword")
+	}
+	return nil
+}
+
+// flush adds the current message to the catalog, and starts a new message
+func (p *poParser) flush() error {
+	defer func() {
+		p.msg = &Message{}
+		p.hasID = false
+		p.state = poStateNone
+		p.prevState = poStateNone
+	}()
+
+	if !p.hasID {
+		if p.state == poStateContext {
+			return p.error("'msgctxt' must be followed by 'msgid'")
+		}
+		// Comments at the end of the file
+		return nil
+	}
+
+	if p.state != poStateStr {
+		return p.error("missing 'msgstr' for msgid %q", p.msg.ID)
+	}
+
+	// Messages defined more than once replace the earlier definition
+	p.cat.Add(p.msg)
+	return nil
+}
+
+// unquote parses a C-style quoted string
+func (p *poParser) unquote(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", p.error("expected a quoted string")
+	}
+	s = s[1 : len(s)-1]
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", p.error("unescaped '\"' in string")
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i >= len(s) {
+			return "", p.error("invalid escape sequence at end of string")
+		}
+
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		case '\\', '"', '\'', '?':
+			sb.WriteByte(s[i])
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := 0
+			j := i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				n = n*8 + int(s[j]-'0')
+			}
+			sb.WriteByte(byte(n))
+			i = j - 1
+		case 'x':
+			j := i + 1
+			for ; j < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0; j++ {
+			}
+			n, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return "", p.error("invalid hex escape sequence")
+			}
+			sb.WriteByte(byte(n))
+			i = j - 1
+		default:
+			return "", p.error("invalid escape sequence '\\%c'", s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// WritePo writes the catalog in .po-format.
+// Messages are written in the order they appear in c.Messages.
+func WritePo(w io.Writer, c *Catalog) error {
+	bw := bufio.NewWriter(w)
+
+	for k, m := range c.Messages {
+		if k > 0 {
+			bw.WriteString("\n")
+		}
+		writePoMessage(bw, m)
+	}
+	return bw.Flush()
+}
+
+func writePoMessage(w *bufio.Writer, m *Message) {
+	for _, comment := range m.TranslatorComments {
+		if comment == "" {
+			w.WriteString("#\n")
+			continue
+		}
+		w.WriteString("# " + comment + "\n")
+	}
+	for _, comment := range m.ExtractedComments {
+		w.WriteString("#. " + comment + "\n")
+	}
+	if len(m.References) > 0 {
+		w.WriteString("#: " + strings.Join(m.References, " ") + "\n")
+	}
+	if len(m.Flags) > 0 {
+		w.WriteString("#, " + strings.Join(m.Flags, ", ") + "\n")
+	}
+
+	prefix, previousPrefix := "", "#| "
+	if m.Obsolete {
+		prefix, previousPrefix = "#~ ", "#~| "
+	}
+
+	if m.PreviousContext != "" {
+		writePoString(w, previousPrefix, "msgctxt", m.PreviousContext)
+	}
+	if m.PreviousID != "" {
+		writePoString(w, previousPrefix, "msgid", m.PreviousID)
+	}
+	if m.PreviousIDPlural != "" {
+		writePoString(w, previousPrefix, "msgid_plural", m.PreviousIDPlural)
+	}
+
+	if m.Context != "" {
+		writePoString(w, prefix, "msgctxt", m.Context)
+	}
+	writePoString(w, prefix, "msgid", m.ID)
+
+	if m.IDPlural != "" {
+		writePoString(w, prefix, "msgid_plural", m.IDPlural)
+
+		str := m.Str
+		if len(str) == 0 {
+			str = []string{"", ""}
+		}
+		for k, s := range str {
+			writePoString(w, prefix, fmt.Sprintf("msgstr[%d]", k), s)
+		}
+		return
+	}
+
+	str := ""
+	if len(m.Str) > 0 {
+		str = m.Str[0]
+	}
+	writePoString(w, prefix, "msgstr", str)
+}
+
+// writePoString writes a keyword and its quoted value.
+// Values containing newlines are split into one line per newline, as done by the gettext tools
+func writePoString(w *bufio.Writer, prefix, keyword, value string) {
+	lines := strings.SplitAfter(value, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) <= 1 {
+		w.WriteString(prefix + keyword + " " + quote(value) + "\n")
+		return
+	}
+
+	w.WriteString(prefix + keyword + " \"\"\n")
+	for _, line := range lines {
+		w.WriteString(prefix + quote(line) + "\n")
+	}
+}
+
+// quote returns s as a C-style quoted string
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&sb, `\%03o`, c)
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
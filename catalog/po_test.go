@@ -0,0 +1,254 @@
+package catalog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPo = `# Translator comment
+#
+msgid ""
+msgstr ""
+"Language: sv_SE\n"
+"Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+#. Extracted comment
+#: templates/index.html:1 templates/index.html:5
+#: templates/other.html:3
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+#, fuzzy, python-format
+#| msgid "Old message"
+msgid "Multi"
+"line"
+msgstr ""
+"Fler"
+"rader"
+
+msgctxt "month"
+msgid "May"
+msgstr "Maj"
+
+msgid "One file"
+msgid_plural "Many files"
+msgstr[0] "En fil"
+msgstr[1] "Flera \"filer\"\n"
+
+msgid "Untranslated"
+msgstr ""
+
+#~ msgid "Obsolete"
+#~ msgstr "Föråldrad"
+`
+
+func TestParsePo(t *testing.T) {
+	cat, err := ParsePo([]byte(testPo))
+	require.Nil(t, err)
+	require.Len(t, cat.Messages, 7)
+
+	header := cat.HeaderMessage()
+	require.NotNil(t, header)
+	require.Equal(t, []string{"Translator comment", ""}, header.TranslatorComments)
+	require.Equal(t, "sv_SE", cat.Header("Language"))
+	require.Equal(t, "sv_SE", cat.Header("language"))
+	require.Equal(t, 2, cat.NPlurals())
+
+	m := cat.Lookup("", "Hello world!")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"Hej världen!"}, m.Str)
+	require.Equal(t, []string{"Extracted comment"}, m.ExtractedComments)
+	require.Equal(t, []string{"templates/index.html:1", "templates/index.html:5", "templates/other.html:3"}, m.References)
+
+	m = cat.Lookup("", "Multiline")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"Flerrader"}, m.Str)
+	require.Equal(t, []string{"fuzzy", "python-format"}, m.Flags)
+	require.Equal(t, "Old message", m.PreviousID)
+	require.True(t, m.IsFuzzy())
+
+	// Fuzzy messages are not used as translations
+	_, ok := cat.Translation("", "Multiline")
+	require.False(t, ok)
+
+	require.Nil(t, cat.Lookup("", "May"))
+	tr, ok := cat.Translation("month", "May")
+	require.True(t, ok)
+	require.Equal(t, "Maj", tr)
+
+	tr, ok = cat.PluralTranslation("", "One file", 1)
+	require.True(t, ok)
+	require.Equal(t, "En fil", tr)
+	tr, ok = cat.PluralTranslation("", "One file", 5)
+	require.True(t, ok)
+	require.Equal(t, "Flera \"filer\"\n", tr)
+
+	_, ok = cat.Translation("", "Untranslated")
+	require.False(t, ok)
+	require.False(t, cat.Lookup("", "Untranslated").IsTranslated())
+
+	// Obsolete messages are kept, but never used
+	require.Nil(t, cat.Lookup("", "Obsolete"))
+	require.True(t, cat.Messages[6].Obsolete)
+	require.Equal(t, "Obsolete", cat.Messages[6].ID)
+}
+
+func TestParsePo_Duplicates(t *testing.T) {
+	cat, err := ParsePo([]byte(`msgid "a"
+msgstr "b"
+
+msgctxt "c"
+msgid "a"
+msgstr "d"
+
+msgid "a"
+msgstr "e"
+`))
+	require.Nil(t, err)
+	require.Len(t, cat.Messages, 2)
+
+	tr, ok := cat.Translation("", "a")
+	require.True(t, ok)
+	require.Equal(t, "e", tr)
+
+	tr, ok = cat.Translation("c", "a")
+	require.True(t, ok)
+	require.Equal(t, "d", tr)
+}
+
+func TestParsePo_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		line  int
+	}{
+		{input: "msgid \"a\"\nmsgstr \"b\nc\"", line: 2},
+		{input: "msgid \"a\"\nmsgstr b", line: 2},
+		{input: "msgid \"a\"\n\"b\" c\"\nmsgstr \"\"", line: 2},
+		{input: "msgid \"a\"\nmsgid \"b\"", line: 2},
+		{input: "msgstr \"a\"", line: 1},
+		{input: "\"a\"", line: 1},
+		{input: "msgid \"a\"\nmsgid_plural \"b\"\nmsgstr \"c\"", line: 3},
+		{input: "msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"c\"", line: 3},
+		{input: "msgid \"a\"\nmsgstr \"\\q\"", line: 2},
+		{input: "msgid \"a\"\n", line: 2},
+		{input: "msgctxt \"a\"\n", line: 2},
+		{input: "unknown \"a\"", line: 1},
+	}
+
+	for k, tst := range tests {
+		_, err := ParsePo([]byte(tst.input))
+		require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		parseErr, ok := err.(*ParseError)
+		require.Truef(t, ok, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.line, parseErr.Line, "test: %d, input: %s, error: %s", k, tst.input, err)
+	}
+}
+
+func TestCatalog_PluralForms(t *testing.T) {
+	tests := []struct {
+		header   string
+		nplurals int
+		forms    []int // plural form for 0, 1, 2, 5, 11, 21
+	}{
+		{header: "nplurals=2; plural=n != 1;", nplurals: 2, forms: []int{1, 0, 1, 1, 1, 1}},
+		{header: " nplurals = 1 ; plural=0;", nplurals: 1, forms: []int{0, 0, 0, 0, 0, 0}},
+		{header: "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);", nplurals: 3, forms: []int{2, 0, 1, 2, 2, 0}},
+		{header: "nplurals=x; plural=n != 1;", nplurals: 0, forms: []int{1, 0, 1, 1, 1, 1}},
+		{header: "", nplurals: 0, forms: []int{1, 0, 1, 1, 1, 1}},
+	}
+
+	for k, tst := range tests {
+		cat := New()
+		cat.Add(&Message{Str: []string{"Plural-Forms: " + tst.header + "\n"}})
+		require.Equalf(t, tst.nplurals, cat.NPlurals(), "test: %d", k)
+
+		for i, n := range []int{0, 1, 2, 5, 11, 21} {
+			require.Equalf(t, tst.forms[i], cat.PluralForm(n), "test: %d, n: %d", k, n)
+		}
+	}
+}
+
+func TestWritePo(t *testing.T) {
+	cat, err := ParsePo([]byte(testPo))
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	require.Nil(t, WritePo(&buf, cat))
+	require.Equal(t, `# Translator comment
+#
+msgid ""
+msgstr ""
+"Language: sv_SE\n"
+"Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+#. Extracted comment
+#: templates/index.html:1 templates/index.html:5 templates/other.html:3
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+#, fuzzy, python-format
+#| msgid "Old message"
+msgid "Multiline"
+msgstr "Flerrader"
+
+msgctxt "month"
+msgid "May"
+msgstr "Maj"
+
+msgid "One file"
+msgid_plural "Many files"
+msgstr[0] "En fil"
+msgstr[1] "Flera \"filer\"\n"
+
+msgid "Untranslated"
+msgstr ""
+
+#~ msgid "Obsolete"
+#~ msgstr "Föråldrad"
+`, buf.String())
+
+	// The written catalog should be parsed to the same messages
+	written, err := ParsePo(buf.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, cat.Messages, written.Messages)
+
+	// Control characters should be escaped, and untranslated plurals should get empty translations
+	cat = New()
+	cat.Add(&Message{ID: "a\tb\\c\x01", Str: []string{"x\ny\n"}})
+	cat.Add(&Message{ID: "file", IDPlural: "files"})
+
+	buf.Reset()
+	require.Nil(t, WritePo(&buf, cat))
+	require.Equal(t, `msgid "a\tb\\c\001"
+msgstr ""
+"x\n"
+"y\n"
+
+msgid "file"
+msgid_plural "files"
+msgstr[0] ""
+msgstr[1] ""
+`, buf.String())
+
+	written, err = ParsePo(buf.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, "x\ny\n", written.Messages[0].Str[0])
+}
+
+func TestCatalog_Stats(t *testing.T) {
+	cat, err := ParsePo([]byte(testPo))
+	require.Nil(t, err)
+
+	stats := cat.Stats()
+	require.Equal(t, Stats{Total: 5, Translated: 3, Fuzzy: 1, Untranslated: 1}, stats)
+	require.Equal(t, 60.0, stats.Percent())
+
+	// Only the messages accepted by the function are counted by StatsFunc
+	stats = cat.StatsFunc(func(m *Message) bool { return !m.IsFuzzy() })
+	require.Equal(t, Stats{Total: 4, Translated: 3, Untranslated: 1}, stats)
+
+	require.Equal(t, 100.0, New().Stats().Percent())
+}
@@ -0,0 +1,637 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// XLIFFVersion is the version of the XLIFF-files written by WriteXLIFF
+type XLIFFVersion string
+
+// Supported XLIFF versions
+const (
+	XLIFF12 XLIFFVersion = "1.2"
+	XLIFF20 XLIFFVersion = "2.0"
+)
+
+const (
+	xliff12Namespace = "urn:oasis:names:tc:xliff:document:1.2"
+	xliff20Namespace = "urn:oasis:names:tc:xliff:document:2.0"
+
+	// Names used to store gettext information that has no direct equivalent in XLIFF
+	xliffHeaderNote  = "x-gettext-header"
+	xliffContextType = "x-gettext-msgctxt"
+	xliff12Plurals   = "x-gettext-plurals"
+	xliff20Plurals   = "x-gettext:plurals"
+	xliff20Fuzzy     = "x-gettext:fuzzy"
+)
+
+// XLIFFOption is used to change how catalogs are written by WriteXLIFF
+type XLIFFOption func(*xliffOptions)
+
+type xliffOptions struct {
+	version        XLIFFVersion
+	sourceLanguage string
+	original       string
+}
+
+// WithXLIFFVersion sets the XLIFF version to write. The default is XLIFF12
+func WithXLIFFVersion(version XLIFFVersion) XLIFFOption {
+	return func(o *xliffOptions) {
+		o.version = version
+	}
+}
+
+// WithSourceLanguage sets the language of the msgids. The default is 'en'
+func WithSourceLanguage(language string) XLIFFOption {
+	return func(o *xliffOptions) {
+		o.sourceLanguage = language
+	}
+}
+
+// WithOriginal sets the name of the original file in the XLIFF-file, usually the domain. The default is 'messages'
+func WithOriginal(original string) XLIFFOption {
+	return func(o *xliffOptions) {
+		o.original = original
+	}
+}
+
+// xliffNote is a note in both XLIFF 1.2 ('from') and 2.0 ('category')
+type xliffNote struct {
+	From     string `xml:"from,attr,omitempty"`
+	Category string `xml:"category,attr,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+type xliffTarget struct {
+	State string `xml:"state,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+type xliff12Context struct {
+	Type string `xml:"context-type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xliff12ContextGroup struct {
+	Purpose  string           `xml:"purpose,attr,omitempty"`
+	Contexts []xliff12Context `xml:"context"`
+}
+
+// xliff12Item is either a <group> or a <trans-unit>
+type xliff12Item struct {
+	XMLName       xml.Name
+	ID            string                `xml:"id,attr"`
+	Restype       string                `xml:"restype,attr,omitempty"`
+	Source        *string               `xml:"source"`
+	Target        *xliffTarget          `xml:"target"`
+	Notes         []xliffNote           `xml:"note"`
+	ContextGroups []xliff12ContextGroup `xml:"context-group"`
+	Items         []xliff12Item         `xml:",any"`
+}
+
+type xliff12File struct {
+	Original       string      `xml:"original,attr"`
+	SourceLanguage string      `xml:"source-language,attr"`
+	TargetLanguage string      `xml:"target-language,attr,omitempty"`
+	Datatype       string      `xml:"datatype,attr"`
+	HeaderNotes    []xliffNote `xml:"header>note"`
+	Body           xliff12Body `xml:"body"`
+}
+
+type xliff12Body struct {
+	Items []xliff12Item `xml:",any"`
+}
+
+type xliff12 struct {
+	XMLName xml.Name
+	Version string        `xml:"version,attr"`
+	Files   []xliff12File `xml:"file"`
+}
+
+type xliff20Segment struct {
+	State    string  `xml:"state,attr,omitempty"`
+	SubState string  `xml:"subState,attr,omitempty"`
+	Source   string  `xml:"source"`
+	Target   *string `xml:"target"`
+}
+
+// xliff20Item is either a <group> or a <unit>
+type xliff20Item struct {
+	XMLName  xml.Name
+	ID       string           `xml:"id,attr"`
+	Type     string           `xml:"type,attr,omitempty"`
+	Notes    *xliff20Notes    `xml:"notes"`
+	Segments []xliff20Segment `xml:"segment"`
+	Items    []xliff20Item    `xml:",any"`
+}
+
+type xliff20File struct {
+	ID       string        `xml:"id,attr"`
+	Original string        `xml:"original,attr,omitempty"`
+	Notes    *xliff20Notes `xml:"notes"`
+	Items    []xliff20Item `xml:",any"`
+}
+
+// xliff20Notes is only written if there are any notes, since <notes> must contain at least one note
+type xliff20Notes struct {
+	Notes []xliffNote `xml:"note"`
+}
+
+func newXliff20Notes(notes []xliffNote) *xliff20Notes {
+	if len(notes) == 0 {
+		return nil
+	}
+	return &xliff20Notes{Notes: notes}
+}
+
+func (n *xliff20Notes) list() []xliffNote {
+	if n == nil {
+		return nil
+	}
+	return n.Notes
+}
+
+type xliff20 struct {
+	XMLName xml.Name
+	Version string        `xml:"version,attr"`
+	SrcLang string        `xml:"srcLang,attr"`
+	TrgLang string        `xml:"trgLang,attr,omitempty"`
+	Files   []xliff20File `xml:"file"`
+}
+
+// xliffMessage contains the parts of a message that are stored in XLIFF, independent of the version
+type xliffMessage struct {
+	msg    *Message
+	fuzzy  bool
+	plural bool
+}
+
+// WriteXLIFF writes a catalog as an XLIFF-file, which can be sent to a translation agency.
+// Contexts, references, translator and extracted comments, plural messages and fuzzy markers are kept,
+// and the catalog header is stored as a note, so that the catalog can be read back with ParseXLIFF.
+// Obsolete messages are skipped.
+func WriteXLIFF(w io.Writer, c *Catalog, options ...XLIFFOption) error {
+	o := xliffOptions{version: XLIFF12, sourceLanguage: "en", original: "messages"}
+	for _, option := range options {
+		option(&o)
+	}
+
+	targetLanguage := strings.Replace(c.Header("Language"), "_", "-", -1)
+	var header string
+	if h := c.HeaderMessage(); h != nil && len(h.Str) > 0 {
+		header = h.Str[0]
+	}
+
+	var doc interface{}
+	switch o.version {
+	case XLIFF12:
+		file := xliff12File{
+			Original:       o.original,
+			SourceLanguage: o.sourceLanguage,
+			TargetLanguage: targetLanguage,
+			Datatype:       "plaintext",
+		}
+		if header != "" {
+			file.HeaderNotes = []xliffNote{{From: xliffHeaderNote, Text: header}}
+		}
+		for k, m := range xliffMessages(c) {
+			file.Body.Items = append(file.Body.Items, m.xliff12(strconv.Itoa(k+1)))
+		}
+		doc = xliff12{XMLName: xml.Name{Space: xliff12Namespace, Local: "xliff"}, Version: string(XLIFF12), Files: []xliff12File{file}}
+
+	case XLIFF20:
+		file := xliff20File{ID: "f1", Original: o.original}
+		if header != "" {
+			file.Notes = newXliff20Notes([]xliffNote{{Category: xliffHeaderNote, Text: header}})
+		}
+		for k, m := range xliffMessages(c) {
+			file.Items = append(file.Items, m.xliff20(strconv.Itoa(k+1)))
+		}
+		doc = xliff20{
+			XMLName: xml.Name{Space: xliff20Namespace, Local: "xliff"},
+			Version: string(XLIFF20),
+			SrcLang: o.sourceLanguage,
+			TrgLang: targetLanguage,
+			Files:   []xliff20File{file},
+		}
+
+	default:
+		return fmt.Errorf("unsupported XLIFF version %q", o.version)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// xliffMessages returns all messages to write to an XLIFF-file
+func xliffMessages(c *Catalog) []xliffMessage {
+	var messages []xliffMessage
+	for _, m := range c.Messages {
+		if m.Obsolete || (m.ID == "" && m.Context == "") {
+			continue
+		}
+		messages = append(messages, xliffMessage{msg: m, fuzzy: m.IsFuzzy(), plural: m.IDPlural != ""})
+	}
+	return messages
+}
+
+func (m xliffMessage) sources() []string {
+	if !m.plural {
+		return []string{m.msg.ID}
+	}
+
+	n := len(m.msg.Str)
+	if n < 2 {
+		n = 2
+	}
+	sources := []string{m.msg.ID}
+	for len(sources) < n {
+		sources = append(sources, m.msg.IDPlural)
+	}
+	return sources
+}
+
+func (m xliffMessage) target(k int) string {
+	if k < len(m.msg.Str) {
+		return m.msg.Str[k]
+	}
+	return ""
+}
+
+func (m xliffMessage) xliff12(id string) xliff12Item {
+	var notes []xliffNote
+	if len(m.msg.TranslatorComments) > 0 {
+		notes = append(notes, xliffNote{From: "translator", Text: strings.Join(m.msg.TranslatorComments, "\n")})
+	}
+	if len(m.msg.ExtractedComments) > 0 {
+		notes = append(notes, xliffNote{From: "developer", Text: strings.Join(m.msg.ExtractedComments, "\n")})
+	}
+
+	var groups []xliff12ContextGroup
+	if m.msg.Context != "" {
+		groups = append(groups, xliff12ContextGroup{
+			Purpose:  "information",
+			Contexts: []xliff12Context{{Type: xliffContextType, Text: m.msg.Context}},
+		})
+	}
+	for _, ref := range m.msg.References {
+		group := xliff12ContextGroup{Purpose: "location"}
+		file, line := splitReference(ref)
+		group.Contexts = append(group.Contexts, xliff12Context{Type: "sourcefile", Text: file})
+		if line != "" {
+			group.Contexts = append(group.Contexts, xliff12Context{Type: "linenumber", Text: line})
+		}
+		groups = append(groups, group)
+	}
+
+	unit := func(id, source, target string) xliff12Item {
+		item := xliff12Item{XMLName: xml.Name{Local: "trans-unit"}, ID: id, Source: &source}
+		if target != "" {
+			state := "translated"
+			if m.fuzzy {
+				state = "needs-review-translation"
+			}
+			item.Target = &xliffTarget{State: state, Text: target}
+		}
+		return item
+	}
+
+	if !m.plural {
+		item := unit(id, m.msg.ID, m.target(0))
+		item.Notes = notes
+		item.ContextGroups = groups
+		return item
+	}
+
+	group := xliff12Item{
+		XMLName:       xml.Name{Local: "group"},
+		ID:            id,
+		Restype:       xliff12Plurals,
+		Notes:         notes,
+		ContextGroups: groups,
+	}
+	for k, source := range m.sources() {
+		group.Items = append(group.Items, unit(fmt.Sprintf("%s[%d]", id, k), source, m.target(k)))
+	}
+	return group
+}
+
+func (m xliffMessage) xliff20(id string) xliff20Item {
+	var notes []xliffNote
+	if m.msg.Context != "" {
+		notes = append(notes, xliffNote{Category: xliffContextType, Text: m.msg.Context})
+	}
+	if len(m.msg.TranslatorComments) > 0 {
+		notes = append(notes, xliffNote{Category: "translator", Text: strings.Join(m.msg.TranslatorComments, "\n")})
+	}
+	if len(m.msg.ExtractedComments) > 0 {
+		notes = append(notes, xliffNote{Category: "developer", Text: strings.Join(m.msg.ExtractedComments, "\n")})
+	}
+	for _, ref := range m.msg.References {
+		notes = append(notes, xliffNote{Category: "location", Text: ref})
+	}
+
+	unit := func(id, source, target string) xliff20Item {
+		segment := xliff20Segment{State: "initial", Source: source}
+		if target != "" {
+			segment.Target = &target
+			segment.State = "translated"
+			if m.fuzzy {
+				segment.State = "initial"
+				segment.SubState = xliff20Fuzzy
+			}
+		}
+		return xliff20Item{XMLName: xml.Name{Local: "unit"}, ID: id, Segments: []xliff20Segment{segment}}
+	}
+
+	if !m.plural {
+		item := unit(id, m.msg.ID, m.target(0))
+		item.Notes = newXliff20Notes(notes)
+		return item
+	}
+
+	// Identifiers in XLIFF 2.0 can't contain brackets
+	group := xliff20Item{XMLName: xml.Name{Local: "group"}, ID: id, Type: xliff20Plurals, Notes: newXliff20Notes(notes)}
+	for k, source := range m.sources() {
+		group.Items = append(group.Items, unit(fmt.Sprintf("%s-%d", id, k), source, m.target(k)))
+	}
+	return group
+}
+
+// splitReference splits a reference such as 'templates/index.html:3' into the filename and line number
+func splitReference(ref string) (string, string) {
+	idx := strings.LastIndex(ref, ":")
+	if idx < 0 {
+		return ref, ""
+	}
+	if _, err := strconv.Atoi(ref[idx+1:]); err != nil {
+		return ref, ""
+	}
+	return ref[:idx], ref[idx+1:]
+}
+
+// ParseXLIFF parses the contents of an XLIFF 1.2 or 2.0-file. All files in the XLIFF-file are added to the same catalog.
+//
+// The catalog header is read from the note written by WriteXLIFF. If there is no such note, a header is created
+// with the target language, and the plural forms are selected by the CLDR plural rules for the language.
+func ParseXLIFF(data []byte) (*Catalog, error) {
+	var root struct {
+		Version string `xml:"version,attr"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, &ParseError{Msg: err.Error()}
+	}
+
+	c := New()
+	var header, targetLanguage string
+	var messages []xliffMessage
+
+	switch {
+	case strings.HasPrefix(root.Version, "1."):
+		var doc xliff12
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, &ParseError{Msg: err.Error()}
+		}
+
+		for _, file := range doc.Files {
+			targetLanguage = file.TargetLanguage
+			for _, note := range file.HeaderNotes {
+				if note.From == xliffHeaderNote {
+					header = note.Text
+				}
+			}
+			messages = append(messages, parseXliff12Items(file.Body.Items)...)
+		}
+
+	case strings.HasPrefix(root.Version, "2."):
+		var doc xliff20
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, &ParseError{Msg: err.Error()}
+		}
+
+		targetLanguage = doc.TrgLang
+		for _, file := range doc.Files {
+			for _, note := range file.Notes.list() {
+				if note.Category == xliffHeaderNote {
+					header = note.Text
+				}
+			}
+			messages = append(messages, parseXliff20Items(file.Items)...)
+		}
+
+	default:
+		return nil, &ParseError{Msg: fmt.Sprintf("unsupported XLIFF version %q", root.Version)}
+	}
+
+	if header != "" {
+		c.Add(&Message{Str: []string{header}})
+	} else {
+		lang := strings.Replace(targetLanguage, "-", "_", -1)
+		c.Add(&Message{Str: []string{"Language: " + lang + "\n" +
+			"MIME-Version: 1.0\n" +
+			"Content-Type: text/plain; charset=UTF-8\n" +
+			"Content-Transfer-Encoding: 8bit\n"}})
+
+		tag, err := language.Parse(targetLanguage)
+		if err != nil {
+			tag = language.Und
+		}
+		forms, selectForm := cldrPlurals(tag)
+		c.SetPluralFunc(len(forms), selectForm)
+	}
+
+	for _, m := range messages {
+		if m.fuzzy {
+			m.msg.Flags = append(m.msg.Flags, "fuzzy")
+		}
+		c.Add(m.msg)
+	}
+	return c, nil
+}
+
+// parseXliffNote adds a note to a message, and returns false if the note is not recognized
+func parseXliffNote(msg *Message, kind, text string) bool {
+	switch kind {
+	case xliffContextType:
+		msg.Context = text
+	case "translator":
+		msg.TranslatorComments = append(msg.TranslatorComments, strings.Split(text, "\n")...)
+	case "developer":
+		msg.ExtractedComments = append(msg.ExtractedComments, strings.Split(text, "\n")...)
+	case "location":
+		msg.References = append(msg.References, text)
+	default:
+		return false
+	}
+	return true
+}
+
+func parseXliff12Items(items []xliff12Item) []xliffMessage {
+	var messages []xliffMessage
+	for _, item := range items {
+		switch item.XMLName.Local {
+		case "group":
+			if item.Restype != xliff12Plurals {
+				messages = append(messages, parseXliff12Items(item.Items)...)
+				continue
+			}
+
+			m := xliffMessage{msg: &Message{}, plural: true}
+			parseXliff12Metadata(m.msg, item)
+			for k, unit := range item.Items {
+				if unit.XMLName.Local != "trans-unit" || unit.Source == nil {
+					continue
+				}
+
+				switch k {
+				case 0:
+					m.msg.ID = *unit.Source
+				case 1:
+					m.msg.IDPlural = *unit.Source
+				}
+				target, fuzzy := parseXliff12Target(unit.Target)
+				m.msg.Str = append(m.msg.Str, target)
+				m.fuzzy = m.fuzzy || fuzzy
+			}
+			messages = append(messages, m)
+
+		case "trans-unit":
+			if item.Source == nil {
+				continue
+			}
+
+			m := xliffMessage{msg: &Message{ID: *item.Source}}
+			parseXliff12Metadata(m.msg, item)
+			target, fuzzy := parseXliff12Target(item.Target)
+			m.msg.Str = []string{target}
+			m.fuzzy = fuzzy
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func parseXliff12Metadata(msg *Message, item xliff12Item) {
+	for _, note := range item.Notes {
+		// Notes from unknown sources are kept as translator comments
+		if !parseXliffNote(msg, note.From, note.Text) {
+			parseXliffNote(msg, "translator", note.Text)
+		}
+	}
+
+	for _, group := range item.ContextGroups {
+		var file, line string
+		for _, ctx := range group.Contexts {
+			switch ctx.Type {
+			case xliffContextType:
+				msg.Context = ctx.Text
+			case "sourcefile":
+				file = ctx.Text
+			case "linenumber":
+				line = ctx.Text
+			}
+		}
+
+		switch {
+		case file != "" && line != "":
+			msg.References = append(msg.References, file+":"+line)
+		case file != "":
+			msg.References = append(msg.References, file)
+		}
+	}
+}
+
+// parseXliff12Target returns the translation, and whether it needs to be reviewed
+func parseXliff12Target(target *xliffTarget) (string, bool) {
+	if target == nil || target.Text == "" {
+		return "", false
+	}
+
+	switch target.State {
+	case "", "translated", "signed-off", "final":
+		return target.Text, false
+	}
+	return target.Text, true
+}
+
+func parseXliff20Items(items []xliff20Item) []xliffMessage {
+	var messages []xliffMessage
+	for _, item := range items {
+		switch item.XMLName.Local {
+		case "group":
+			if item.Type != xliff20Plurals {
+				messages = append(messages, parseXliff20Items(item.Items)...)
+				continue
+			}
+
+			m := xliffMessage{msg: &Message{}, plural: true}
+			parseXliff20Notes(m.msg, item.Notes.list())
+			k := 0
+			for _, unit := range item.Items {
+				if unit.XMLName.Local != "unit" {
+					continue
+				}
+
+				source, target, fuzzy := parseXliff20Segments(unit.Segments)
+				switch k {
+				case 0:
+					m.msg.ID = source
+				case 1:
+					m.msg.IDPlural = source
+				}
+				m.msg.Str = append(m.msg.Str, target)
+				m.fuzzy = m.fuzzy || fuzzy
+				k++
+			}
+			messages = append(messages, m)
+
+		case "unit":
+			source, target, fuzzy := parseXliff20Segments(item.Segments)
+			m := xliffMessage{msg: &Message{ID: source, Str: []string{target}}, fuzzy: fuzzy}
+			parseXliff20Notes(m.msg, item.Notes.list())
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func parseXliff20Notes(msg *Message, notes []xliffNote) {
+	for _, note := range notes {
+		if !parseXliffNote(msg, note.Category, note.Text) {
+			parseXliffNote(msg, "translator", note.Text)
+		}
+	}
+}
+
+// parseXliff20Segments joins the segments of a unit, and returns the source, the translation,
+// and whether the translation needs to be reviewed
+func parseXliff20Segments(segments []xliff20Segment) (string, string, bool) {
+	var source, target strings.Builder
+	fuzzy := false
+	for _, segment := range segments {
+		source.WriteString(segment.Source)
+		if segment.Target == nil || *segment.Target == "" {
+			continue
+		}
+
+		target.WriteString(*segment.Target)
+		if segment.State == "initial" || segment.SubState == xliff20Fuzzy {
+			fuzzy = true
+		}
+	}
+	return source.String(), target.String(), fuzzy
+}
@@ -0,0 +1,136 @@
+package catalog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteXLIFF(t *testing.T) {
+	po, err := ParsePo([]byte(testPo))
+	require.Nil(t, err)
+
+	for _, version := range []XLIFFVersion{XLIFF12, XLIFF20} {
+		var buf bytes.Buffer
+		require.Nil(t, WriteXLIFF(&buf, po, WithXLIFFVersion(version), WithSourceLanguage("en-US"), WithOriginal("default")))
+		require.Containsf(t, buf.String(), `"urn:oasis:names:tc:xliff:document:`+string(version)+`"`, "version: %s", version)
+		require.Containsf(t, buf.String(), `sv-SE`, "version: %s", version)
+
+		cat, err := ParseXLIFF(buf.Bytes())
+		require.Nilf(t, err, "version: %s", version)
+		require.Equalf(t, po.HeaderMessage().Str, cat.HeaderMessage().Str, "version: %s", version)
+		require.Equalf(t, 2, cat.NPlurals(), "version: %s", version)
+
+		// Obsolete messages are not exported
+		require.Lenf(t, cat.Messages, 6, "version: %s", version)
+
+		m := cat.Lookup("", "Hello world!")
+		require.NotNilf(t, m, "version: %s", version)
+		require.Equalf(t, []string{"Hej världen!"}, m.Str, "version: %s", version)
+		require.Equalf(t, []string{"Extracted comment"}, m.ExtractedComments, "version: %s", version)
+		require.Equalf(t, []string{"templates/index.html:1", "templates/index.html:5", "templates/other.html:3"}, m.References, "version: %s", version)
+		require.Falsef(t, m.IsFuzzy(), "version: %s", version)
+
+		m = cat.Lookup("", "Multiline")
+		require.NotNilf(t, m, "version: %s", version)
+		require.Equalf(t, []string{"Flerrader"}, m.Str, "version: %s", version)
+		require.Truef(t, m.IsFuzzy(), "version: %s", version)
+
+		tr, ok := cat.Translation("month", "May")
+		require.Truef(t, ok, "version: %s", version)
+		require.Equalf(t, "Maj", tr, "version: %s", version)
+
+		m = cat.Lookup("", "One file")
+		require.NotNilf(t, m, "version: %s", version)
+		require.Equalf(t, "Many files", m.IDPlural, "version: %s", version)
+		require.Equalf(t, []string{"En fil", "Flera \"filer\"\n"}, m.Str, "version: %s", version)
+
+		m = cat.Lookup("", "Untranslated")
+		require.NotNilf(t, m, "version: %s", version)
+		require.Equalf(t, []string{""}, m.Str, "version: %s", version)
+		require.Falsef(t, m.IsFuzzy(), "version: %s", version)
+	}
+
+	require.NotNil(t, WriteXLIFF(&bytes.Buffer{}, po, WithXLIFFVersion("3.0")))
+}
+
+func TestParseXLIFF(t *testing.T) {
+	// Files from other tools don't contain the catalog header
+	xliff12 := `<?xml version="1.0" encoding="UTF-8"?>
+<xliff version="1.2" xmlns="urn:oasis:names:tc:xliff:document:1.2">
+  <file original="default" source-language="en" target-language="ru" datatype="plaintext">
+    <body>
+      <group id="nav">
+        <trans-unit id="1">
+          <source>Home</source>
+          <target state="final">Главная</target>
+          <note>Shown in the menu</note>
+        </trans-unit>
+      </group>
+      <trans-unit id="2">
+        <source>About</source>
+        <target state="needs-review-translation">О нас</target>
+      </trans-unit>
+      <group id="3" restype="x-gettext-plurals">
+        <trans-unit id="3[0]"><source>{{ n }} file</source><target>{{ n }} файл</target></trans-unit>
+        <trans-unit id="3[1]"><source>{{ n }} files</source><target>{{ n }} файла</target></trans-unit>
+        <trans-unit id="3[2]"><source>{{ n }} files</source><target>{{ n }} файлов</target></trans-unit>
+      </group>
+    </body>
+  </file>
+</xliff>`
+
+	cat, err := ParseXLIFF([]byte(xliff12))
+	require.Nil(t, err)
+	require.Equal(t, "ru", cat.Header("Language"))
+	require.Equal(t, 3, cat.NPlurals())
+
+	m := cat.Lookup("", "Home")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"Главная"}, m.Str)
+	require.Equal(t, []string{"Shown in the menu"}, m.TranslatorComments)
+	require.False(t, m.IsFuzzy())
+	require.True(t, cat.Lookup("", "About").IsFuzzy())
+
+	tr, ok := cat.PluralTranslation("", "{{ n }} file", 5)
+	require.True(t, ok)
+	require.Equal(t, "{{ n }} файлов", tr)
+
+	xliff20 := `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="sv-SE">
+  <file id="f1">
+    <unit id="1">
+      <segment state="final"><source>Hello </source><target>Hej </target></segment>
+      <segment state="final"><source>world!</source><target>världen!</target></segment>
+    </unit>
+    <unit id="2">
+      <notes><note category="x-gettext-msgctxt">month</note></notes>
+      <segment state="initial"><source>May</source><target>Maj</target></segment>
+    </unit>
+  </file>
+</xliff>`
+
+	cat, err = ParseXLIFF([]byte(xliff20))
+	require.Nil(t, err)
+	require.Equal(t, "sv_SE", cat.Header("Language"))
+
+	m = cat.Lookup("", "Hello world!")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"Hej världen!"}, m.Str)
+
+	m = cat.Lookup("month", "May")
+	require.NotNil(t, m)
+	require.True(t, m.IsFuzzy())
+
+	for k, data := range []string{
+		`not xml`,
+		`<xliff version="3.0"></xliff>`,
+		`<xliff version="1.2"><file><body><trans-unit>`,
+	} {
+		_, err := ParseXLIFF([]byte(data))
+		require.NotNilf(t, err, "test: %d", k)
+		_, ok := err.(*ParseError)
+		require.Truef(t, ok, "test: %d", k)
+	}
+}
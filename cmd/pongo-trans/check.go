@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	trans "github.com/yzzyx/pongo-trans"
+)
+
+// defaultCatalogExtensions is used for the '-ext'-flag of the commands that read catalogs
+const defaultCatalogExtensions = ".po,.mo"
+
+// catalogExtensions returns an option reading the catalogs with the given comma-separated extensions
+func catalogExtensions(list string) trans.TranslatorOption {
+	exts := splitList(list)
+	for k, ext := range exts {
+		if !strings.HasPrefix(ext, ".") {
+			exts[k] = "." + ext
+		}
+	}
+	return trans.WithCatalogExtensions(exts...)
+}
+
+// checkDir validates all catalogs in dir, and writes the problems found to w.
+// The number of problems is returned
+func checkDir(w io.Writer, dir string, options ...trans.TranslatorOption) (int, error) {
+	issues, err := trans.Validate(os.DirFS(dir), ".", options...)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+	}
+	return len(issues), nil
+}
+
+func check(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	extensions := flags.String("ext", defaultCatalogExtensions, "comma-separated list of catalog file `extensions`")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans check [flags] <locale-dir>...\n\n")
+		fmt.Fprintf(flags.Output(), "Checks all catalogs in the given directories for placeholders that differ from the original message,\n")
+		fmt.Fprintf(flags.Output(), "translations that cannot be parsed, missing plural forms and malformed Plural-Forms-headers.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	total := 0
+	for _, dir := range flags.Args() {
+		n, err := checkDir(os.Stdout, dir, catalogExtensions(*extensions))
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+
+	if total > 0 {
+		return fmt.Errorf("found %d problems", total)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "sv_SE"), 0777))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "sv_SE", "default.po"), []byte(`msgid ""
+msgstr "Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+msgid "Hello {{ name }}"
+msgstr "Hej {{ name }}"
+
+msgid "{{ n }} file"
+msgid_plural "{{ n }} files"
+msgstr[0] "{{ n }} fil"
+`), 0666))
+
+	var buf bytes.Buffer
+	n, err := checkDir(&buf, dir)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, "sv_SE/default: msgid \"{{ n }} file\": msgstr[1] is missing\n", buf.String())
+
+	// Other catalogs for the same domain are only read when their extension is given
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "sv_SE", "default.json"), []byte(`{"Hello {{ name }}": "Hej {{ name }}"}`), 0666))
+	buf.Reset()
+	n, err = checkDir(&buf, dir, catalogExtensions("po,mo,json"))
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Contains(t, buf.String(), "the domain 'default' has more than one catalog: default.json, default.po")
+
+	_, err = checkDir(&buf, dir, catalogExtensions(".txt"))
+	require.NotNil(t, err)
+
+	_, err = checkDir(&buf, filepath.Join(dir, "missing"))
+	require.NotNil(t, err)
+}
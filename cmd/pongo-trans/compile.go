@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+// compileFile compiles a single .po-file to a .mo-file with the same name
+func compileFile(filename string, options ...catalog.MoOption) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	po, err := catalog.ParsePo(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filename, err)
+	}
+
+	var buf bytes.Buffer
+	if err = catalog.WriteMo(&buf, po, options...); err != nil {
+		return "", err
+	}
+
+	output := replaceExtension(filename, ".mo")
+	return output, os.WriteFile(output, buf.Bytes(), 0666)
+}
+
+// compilePaths compiles the given .po-files, and all .po-files in the given directories.
+// The names of the written .mo-files are returned
+func compilePaths(paths []string, options ...catalog.MoOption) ([]string, error) {
+	var written []string
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Files given explicitly are always compiled
+			if d.IsDir() || (filename != p && filepath.Ext(filename) != ".po") {
+				return nil
+			}
+
+			output, err := compileFile(filename, options...)
+			if err != nil {
+				return err
+			}
+			written = append(written, output)
+			return nil
+		})
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func compile(args []string) error {
+	flags := flag.NewFlagSet("compile", flag.ExitOnError)
+	fuzzy := flags.Bool("fuzzy", false, "include messages marked as fuzzy")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans compile [flags] <file.po|dir>...\n\n")
+		fmt.Fprintf(flags.Output(), "Compiles .po-files to .mo-files. Each .mo-file is written next to its .po-file.\n")
+		fmt.Fprintf(flags.Output(), "All .po-files in the given directories are compiled.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var options []catalog.MoOption
+	if *fuzzy {
+		options = append(options, catalog.WithFuzzyMessages())
+	}
+
+	written, err := compilePaths(flags.Args(), options...)
+	for _, filename := range written {
+		fmt.Fprintf(os.Stderr, "wrote %s\n", filename)
+	}
+	return err
+}
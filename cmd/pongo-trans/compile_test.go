@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	trans "github.com/yzzyx/pongo-trans"
+)
+
+func TestCompilePaths(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"sv_SE/default.po": `msgid ""
+msgstr ""
+"Language: sv_SE\n"
+
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+#, fuzzy
+msgid "Fuzzy"
+msgstr "Luddig"
+`,
+		"sv_SE/other.po": `msgid "Other"
+msgstr "Annan"
+`,
+		"sv_SE/README": "Not a catalog",
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		require.Nil(t, os.MkdirAll(filepath.Dir(filename), 0777))
+		require.Nil(t, os.WriteFile(filename, []byte(content), 0666))
+	}
+
+	written, err := compilePaths([]string{dir})
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{
+		filepath.Join(dir, "sv_SE", "default.mo"),
+		filepath.Join(dir, "sv_SE", "other.mo"),
+	}, written)
+
+	// Remove the .po-files, so that the translator only reads the .mo-files
+	require.Nil(t, os.Remove(filepath.Join(dir, "sv_SE", "default.po")))
+	require.Nil(t, os.Remove(filepath.Join(dir, "sv_SE", "other.po")))
+
+	translator, err := trans.NewTemplateTranslator(os.DirFS(dir), ".")
+	require.Nil(t, err)
+
+	ctx := trans.TransCtx{Language: "sv_SE"}
+	tr := translator.Get(ctx, "Hello world!")
+	require.Equal(t, "Hej världen!", tr)
+
+	tr = translator.Get(ctx, "Fuzzy")
+	require.Equal(t, "Fuzzy", tr)
+
+	ctx.Domain = "other"
+	tr = translator.Get(ctx, "Other")
+	require.Equal(t, "Annan", tr)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	trans "github.com/yzzyx/pongo-trans"
+	"github.com/yzzyx/pongo-trans/catalog"
+	"golang.org/x/text/language"
+)
+
+// stubbedTags are replaced by tags that don't do anything when templates are parsed for extraction,
+// since they would otherwise try to load other templates
+var stubbedTags = []string{"include", "extends", "import", "ssi"}
+
+var (
+	unknownTag    = regexp.MustCompile(`^Tag '([^']+)' not found`)
+	unknownFilter = regexp.MustCompile(`^Filter '([^']+)' does not exist`)
+)
+
+type stubNode struct{}
+
+func (stubNode) Execute(*pongo2.ExecutionContext, pongo2.TemplateWriter) *pongo2.Error {
+	return nil
+}
+
+func stubTag(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	return stubNode{}, nil
+}
+
+func stubFilter(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	return in, nil
+}
+
+// extractor extracts messages from templates, using the same parser as the 'trans' and 'blocktrans'-tags
+type extractor struct {
+	cat *catalog.Catalog
+
+	// messages contains the messages found while a template is parsed
+	messages []trans.ExtractedMessage
+}
+
+// newExtractor registers the tags used for extraction. Since pongo2 tags are global, this affects all templates.
+// The plural categories of languages are extracted for the additional counters of 'blocktrans'
+func newExtractor(transTags, blocktransTags, languages []string) *extractor {
+	e := &extractor{cat: catalog.NewTemplate()}
+
+	register := func(name string, parser pongo2.TagParser) {
+		if err := pongo2.RegisterTag(name, parser); err != nil {
+			pongo2.ReplaceTag(name, parser)
+		}
+	}
+
+	for _, name := range stubbedTags {
+		register(name, stubTag)
+	}
+	for _, name := range transTags {
+		register(name, trans.NewTransTag(nil, trans.WithExtractor(e.add), trans.WithoutTemplateCache()))
+	}
+	for _, name := range blocktransTags {
+		register(name, trans.NewBlockTransTag(nil, trans.WithExtractor(e.add), trans.WithoutTemplateCache(),
+			trans.WithExtractedLanguages(languages...)))
+	}
+	return e
+}
+
+func (e *extractor) add(m trans.ExtractedMessage) {
+	e.messages = append(e.messages, m)
+}
+
+// parse extracts all messages from a template.
+// Unknown tags and filters (e.g. custom tags registered by the application) are replaced by stubs.
+func (e *extractor) parse(filename string, data []byte) error {
+	for {
+		e.messages = nil
+		_, err := pongo2.FromBytes(data)
+		if err == nil {
+			break
+		}
+
+		var perr *pongo2.Error
+		if !errors.As(err, &perr) || perr.OrigError == nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+
+		if match := unknownTag.FindStringSubmatch(perr.OrigError.Error()); match != nil {
+			if pongo2.RegisterTag(match[1], stubTag) == nil {
+				continue
+			}
+		}
+		if match := unknownFilter.FindStringSubmatch(perr.OrigError.Error()); match != nil {
+			if pongo2.RegisterFilter(match[1], stubFilter) == nil {
+				continue
+			}
+		}
+		if perr.Line > 0 {
+			return fmt.Errorf("%s:%d: %v", filename, perr.Line, perr.OrigError)
+		}
+		return fmt.Errorf("%s: %v", filename, perr.OrigError)
+	}
+
+	for _, m := range e.messages {
+		reference := fmt.Sprintf("%s:%d", filename, m.Line)
+		if msg := e.cat.Lookup(m.Context, m.ID); msg != nil {
+			if msg.IDPlural == "" {
+				msg.IDPlural = m.IDPlural
+			}
+			msg.References = append(msg.References, reference)
+			continue
+		}
+
+		e.cat.Add(&catalog.Message{
+			Context:    m.Context,
+			ID:         m.ID,
+			IDPlural:   m.IDPlural,
+			References: []string{reference},
+		})
+	}
+	return nil
+}
+
+// extractFS extracts messages from all templates in fsys with one of the given extensions.
+// The names of the templates are prefixed with prefix in the references
+func (e *extractor) extractFS(fsys fs.FS, prefix string, extensions []string) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !hasExtension(name, extensions) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		return e.parse(path.Join(prefix, name), data)
+	})
+}
+
+func hasExtension(name string, extensions []string) bool {
+	for _, ext := range extensions {
+		if strings.EqualFold(path.Ext(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated list, ignoring empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func extract(args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	output := flags.String("o", "messages.pot", "write the messages to `file`, or to stdout if '-'")
+	extensions := flags.String("ext", ".html", "comma-separated list of template file `extensions`")
+	transTags := flags.String("trans", "trans", "comma-separated list of `names` the 'trans'-tag is registered as")
+	blocktransTags := flags.String("blocktrans", "blocktrans", "comma-separated list of `names` the 'blocktrans'-tag is registered as")
+	languages := flags.String("languages", "en", "comma-separated list of `languages` whose plural categories are extracted for additional counters in 'blocktrans'")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans extract [flags] <dir>...\n\n")
+		fmt.Fprintf(flags.Output(), "Extracts all messages from the templates in the given directories, and writes them to a .pot-file.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	exts := splitList(*extensions)
+	for k, ext := range exts {
+		if !strings.HasPrefix(ext, ".") {
+			exts[k] = "." + ext
+		}
+	}
+
+	langs := splitList(*languages)
+	for _, lang := range langs {
+		if _, err := language.Parse(strings.Replace(lang, "_", "-", -1)); err != nil {
+			return fmt.Errorf("invalid language '%s': %v", lang, err)
+		}
+	}
+
+	e := newExtractor(splitList(*transTags), splitList(*blocktransTags), langs)
+	for _, dir := range flags.Args() {
+		// References always use forward slashes
+		if err := e.extractFS(os.DirFS(dir), filepath.ToSlash(filepath.Clean(dir)), exts); err != nil {
+			return err
+		}
+	}
+
+	if *output == "-" {
+		return catalog.WritePo(os.Stdout, e.cat)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+
+	if err = catalog.WritePo(f, e.cat); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
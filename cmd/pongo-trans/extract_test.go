@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+	trans "github.com/yzzyx/pongo-trans"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestExtract(t *testing.T) {
+	templates := fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte(`{% extends "base.html" %}
+{% block content %}
+{% trans "Hello world!" %}
+{% trans "May" context "month" %}
+{% trans name %}
+{% custom_tag with args %}{% trans "Inside custom tag" %}{% endcustom_tag %}
+{{ value|custom_filter:"x" }}
+{% blocktrans count n=items|length with name=user.name %}
+{{ name }} has {{ n }} item
+{% plural %}
+{{ name }} has {{ n }} items
+{% endblocktrans %}
+{% endblock %}`)},
+		"other/footer.html": &fstest.MapFile{Data: []byte(`{% include "header.html" %}
+{% trans "Hello world!" as greeting %}{% blocktrans %}Footer{% endblocktrans %}
+{% blocktrans select gender=user.gender %}Their profile{% case "female" %}Her profile{% endblocktrans %}`)},
+		"ignored.txt": &fstest.MapFile{Data: []byte(`{% trans "Ignored" %}`)},
+	}
+
+	e := newExtractor([]string{"trans"}, []string{"blocktrans"}, []string{"en"})
+	require.Nil(t, e.extractFS(templates, "templates", []string{".html"}))
+
+	require.True(t, e.cat.HeaderMessage().IsFuzzy())
+	require.Len(t, e.cat.Messages, 8)
+
+	m := e.cat.Lookup("", "Hello world!")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"templates/index.html:3", "templates/other/footer.html:2"}, m.References)
+
+	m = e.cat.Lookup("month", "May")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"templates/index.html:4"}, m.References)
+
+	require.NotNil(t, e.cat.Lookup("", "Inside custom tag"))
+	require.NotNil(t, e.cat.Lookup("", "Footer"))
+	require.Nil(t, e.cat.Lookup("", "Ignored"))
+
+	// Each variant of a select is extracted with its own context
+	require.NotNil(t, e.cat.Lookup("", "Their profile"))
+	m = e.cat.Lookup("gender=female", "Her profile")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"templates/other/footer.html:3"}, m.References)
+
+	m = e.cat.Lookup("", "\n{{ name }} has {{ n }} item\n")
+	require.NotNil(t, m)
+	require.Equal(t, "\n{{ name }} has {{ n }} items\n", m.IDPlural)
+	require.Equal(t, []string{"templates/index.html:8"}, m.References)
+
+	// Additional counters are extracted once for every plural category of the languages
+	e = newExtractor([]string{"trans"}, []string{"blocktrans"}, []string{"en", "ru"})
+	require.Nil(t, e.parse("folders.html", []byte(`{% blocktrans count n=files count m=folders %}{{ n }} file in {{ m }} folders`+
+		`{% plural %}{{ n }} files in {{ m }} folders{% case m "one" %}{{ n }} file in {{ m }} folder`+
+		`{% plural %}{{ n }} files in {{ m }} folder{% endblocktrans %}`)))
+	require.Len(t, e.cat.Messages, 5)
+	for _, category := range []string{"few", "many", "other"} {
+		m = e.cat.Lookup("m="+category, "{{ n }} file in {{ m }} folders")
+		require.NotNil(t, m, category)
+		require.Equal(t, "{{ n }} files in {{ m }} folders", m.IDPlural)
+	}
+	m = e.cat.Lookup("m=one", "{{ n }} file in {{ m }} folder")
+	require.NotNil(t, m)
+	require.Equal(t, "{{ n }} files in {{ m }} folder", m.IDPlural)
+
+	// Errors should include the name of the template
+	e = newExtractor([]string{"trans"}, []string{"blocktrans"}, []string{"en"})
+	err := e.extractFS(fstest.MapFS{"broken.html": &fstest.MapFile{Data: []byte("\n{% if %}")}}, "templates", []string{".html"})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "templates/broken.html:2")
+}
+
+// The extracted messages must be the same as the ones looked up when the templates are executed
+func TestExtract_MatchesLookup(t *testing.T) {
+	tpl := `{% trans "a" %}{% trans "b" context "c" %}
+{% blocktrans with x=y %}  Text with {{ x }}  and  {{y|upper}}
+{% endblocktrans %}{% blocktrans count n=1 context "d" %}{{n}} one{% plural %}{{ n }} many{% endblocktrans %}`
+
+	e := newExtractor([]string{"trans"}, []string{"blocktrans"}, []string{"en"})
+	require.Nil(t, e.parse("test.html", []byte(tpl)))
+
+	collector := trans.NewMissingTranslationCollector()
+	tt, err := trans.NewTemplateTranslator(fstest.MapFS{"locales": &fstest.MapFile{Mode: fs.ModeDir | 0755}}, "locales",
+		trans.WithMissingTranslationHandler(collector.Handle))
+	require.Nil(t, err)
+
+	require.Nil(t, pongo2.ReplaceTag("trans", trans.NewTransTag(tt)))
+	require.Nil(t, pongo2.ReplaceTag("blocktrans", trans.NewBlockTransTag(tt)))
+	tmpl, err := pongo2.FromString(tpl)
+	require.Nil(t, err)
+	_, err = tmpl.Execute(pongo2.Context{"_language": "sv", "y": "z"})
+	require.Nil(t, err)
+
+	var extracted, missing bytes.Buffer
+	require.Nil(t, catalog.WritePo(&extracted, withoutReferences(e.cat)))
+	require.Nil(t, catalog.WritePo(&missing, withoutComments(collector.Catalog("default"))))
+	require.Equal(t, missing.String(), extracted.String())
+}
+
+func withoutReferences(cat *catalog.Catalog) *catalog.Catalog {
+	for _, m := range cat.Messages[1:] {
+		m.References = nil
+	}
+	cat.Messages = cat.Messages[1:]
+	return cat
+}
+
+func withoutComments(cat *catalog.Catalog) *catalog.Catalog {
+	for _, m := range cat.Messages[1:] {
+		m.ExtractedComments = nil
+	}
+	cat.Messages = cat.Messages[1:]
+	return cat
+}
@@ -0,0 +1,71 @@
+// Command pongo-trans contains tools for working with translations of pongo2 templates
+//
+// Usage:
+//
+//	pongo-trans   <command> [arguments]
+//
+// The commands are:
+//
+//	check         check catalogs for broken translations
+//	compile       compile .po-files to .mo-files
+//	export-xliff  convert a .po-file to XLIFF
+//	extract       extract messages from templates to a .pot-file
+//	import-xliff  copy the translations in an XLIFF-file to a .po-file
+//	merge         update .po-files with the messages in a .pot-file
+//	stats         show translation statistics for each language
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	run         func(args []string) error
+	description string
+}
+
+var commands = map[string]command{
+	"check":        {run: check, description: "check catalogs for broken translations"},
+	"compile":      {run: compile, description: "compile .po-files to .mo-files"},
+	"export-xliff": {run: exportXLIFF, description: "convert a .po-file to XLIFF"},
+	"extract":      {run: extract, description: "extract messages from templates to a .pot-file"},
+	"import-xliff": {run: importXLIFF, description: "copy the translations in an XLIFF-file to a .po-file"},
+	"merge":        {run: merge, description: "update .po-files with the messages in a .pot-file"},
+	"stats":        {run: stats, description: "show translation statistics for each language"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pongo-trans <command> [arguments]\n\nThe commands are:\n\n")
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%-14s%s\n", name, commands[name].description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"pongo-trans <command> -h\" for more information about a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "pongo-trans: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "pongo-trans %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
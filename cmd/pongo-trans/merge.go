@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+// mergeDir updates all .po-files for domain in dir with the messages in pot.
+// The files are expected to be named '<dir>/<locale>/<domain>.po', the same layout as used by NewTemplateTranslator.
+// The names of the updated files are returned.
+func mergeDir(pot *catalog.Catalog, dir, domain string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		filename := filepath.Join(dir, entry.Name(), domain+".po")
+		data, err := os.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return updated, err
+		}
+
+		po, err := catalog.ParsePo(data)
+		if err != nil {
+			return updated, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		var buf bytes.Buffer
+		if err = catalog.WritePo(&buf, catalog.Merge(po, pot)); err != nil {
+			return updated, err
+		}
+		if err = os.WriteFile(filename, buf.Bytes(), 0666); err != nil {
+			return updated, err
+		}
+		updated = append(updated, filename)
+	}
+	return updated, nil
+}
+
+func merge(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	domain := flags.String("domain", "", "update the .po-files for `domain` (default: the name of the template file)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans merge [flags] <template.pot> <locale-dir>\n\n")
+		fmt.Fprintf(flags.Output(), "Updates all '<locale-dir>/<locale>/<domain>.po' files with the messages in the template.\n")
+		fmt.Fprintf(flags.Output(), "New messages are added, removed messages are marked as obsolete, and changed messages are marked as fuzzy.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	potFile, dir := flags.Arg(0), flags.Arg(1)
+	if *domain == "" {
+		*domain = strings.TrimSuffix(filepath.Base(potFile), filepath.Ext(potFile))
+	}
+
+	data, err := os.ReadFile(potFile)
+	if err != nil {
+		return err
+	}
+
+	pot, err := catalog.ParsePo(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", potFile, err)
+	}
+
+	updated, err := mergeDir(pot, dir, *domain)
+	if err != nil {
+		return err
+	}
+	if len(updated) == 0 {
+		return fmt.Errorf("no files found for domain %q in %s", *domain, dir)
+	}
+
+	for _, filename := range updated {
+		fmt.Fprintf(os.Stderr, "updated %s\n", filename)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestMergeDir(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"sv_SE/default.po": `msgid ""
+msgstr ""
+"Language: sv_SE\n"
+
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+msgid "Goodbye"
+msgstr "Hej då"
+`,
+		"sv_SE/other.po": `msgid "Other"
+msgstr "Annan"
+`,
+		"en_GB/default.po": `msgid "Hello world!"
+msgstr "Hello world!"
+`,
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		require.Nil(t, os.MkdirAll(filepath.Dir(filename), 0777))
+		require.Nil(t, os.WriteFile(filename, []byte(content), 0666))
+	}
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "de_DE"), 0777))
+
+	pot := catalog.NewTemplate()
+	pot.Add(&catalog.Message{ID: "Hello world!", References: []string{"index.html:1"}})
+	pot.Add(&catalog.Message{ID: "New message"})
+
+	updated, err := mergeDir(pot, dir, "default")
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{
+		filepath.Join(dir, "en_GB", "default.po"),
+		filepath.Join(dir, "sv_SE", "default.po"),
+	}, updated)
+
+	data, err := os.ReadFile(filepath.Join(dir, "sv_SE", "default.po"))
+	require.Nil(t, err)
+	require.Equal(t, `msgid ""
+msgstr ""
+"Language: sv_SE\n"
+"POT-Creation-Date: `+pot.Header("POT-Creation-Date")+`\n"
+
+#: index.html:1
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+msgid "New message"
+msgstr ""
+
+#~ msgid "Goodbye"
+#~ msgstr "Hej då"
+`, string(data))
+
+	// Other domains are left untouched
+	data, err = os.ReadFile(filepath.Join(dir, "sv_SE", "other.po"))
+	require.Nil(t, err)
+	require.Equal(t, files["sv_SE/other.po"], string(data))
+}
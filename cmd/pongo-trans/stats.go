@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	trans "github.com/yzzyx/pongo-trans"
+)
+
+// filterStats returns the statistics for the given languages, or all statistics if no languages are given
+func filterStats(stats []trans.TranslationStats, languages []string) []trans.TranslationStats {
+	if len(languages) == 0 {
+		return stats
+	}
+
+	included := map[string]bool{}
+	for _, language := range languages {
+		included[language] = true
+	}
+
+	var filtered []trans.TranslationStats
+	for _, s := range stats {
+		if included[s.Language] {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+func writeStats(w io.Writer, stats []trans.TranslationStats, asJSON bool) error {
+	if asJSON {
+		if stats == nil {
+			stats = []trans.TranslationStats{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "LANGUAGE\tDOMAIN\tTOTAL\tTRANSLATED\tFUZZY\tUNTRANSLATED\tCOMPLETE\n")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%.1f%%\n", s.Language, s.Domain, s.Total, s.Translated, s.Fuzzy, s.Untranslated, s.Percent)
+	}
+	return tw.Flush()
+}
+
+func stats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the statistics as JSON")
+	min := flags.Float64("min", 0, "fail if any domain is translated less than `percent`")
+	languages := flags.String("languages", "", "comma-separated list of `languages` to include (default: all)")
+	extensions := flags.String("ext", defaultCatalogExtensions, "comma-separated list of catalog file `extensions`")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans stats [flags] <locale-dir>\n\n")
+		fmt.Fprintf(flags.Output(), "Shows the number of translated, fuzzy and untranslated messages for each language and domain.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	translator, err := trans.NewTemplateTranslator(os.DirFS(flags.Arg(0)), ".", catalogExtensions(*extensions))
+	if err != nil {
+		return err
+	}
+
+	result := filterStats(translator.Stats(), splitList(*languages))
+	if err = writeStats(os.Stdout, result, *asJSON); err != nil {
+		return err
+	}
+
+	below := 0
+	for _, s := range result {
+		if s.Percent < *min {
+			below++
+		}
+	}
+	if below > 0 {
+		return fmt.Errorf("%d domains are translated less than %.1f%%", below, *min)
+	}
+	return nil
+}
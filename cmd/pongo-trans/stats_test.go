@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	trans "github.com/yzzyx/pongo-trans"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestWriteStats(t *testing.T) {
+	stats := []trans.TranslationStats{
+		{Language: "de_DE", Domain: "default", Stats: catalog.Stats{Total: 4, Untranslated: 4}, Percent: 0},
+		{Language: "sv_SE", Domain: "default", Stats: catalog.Stats{Total: 3, Translated: 1, Fuzzy: 1, Untranslated: 1}, Percent: 100.0 / 3},
+	}
+
+	var buf bytes.Buffer
+	require.Nil(t, writeStats(&buf, stats, false))
+	require.Equal(t, `LANGUAGE  DOMAIN   TOTAL  TRANSLATED  FUZZY  UNTRANSLATED  COMPLETE
+de_DE     default  4      0           0      4             0.0%
+sv_SE     default  3      1           1      1             33.3%
+`, buf.String())
+
+	buf.Reset()
+	require.Nil(t, writeStats(&buf, filterStats(stats, []string{"sv_SE"}), true))
+	require.JSONEq(t, `[{"language": "sv_SE", "domain": "default", "total": 3, "translated": 1, "fuzzy": 1, "untranslated": 1, "percent": 33.333333333333336}]`, buf.String())
+
+	buf.Reset()
+	require.Nil(t, writeStats(&buf, filterStats(stats, []string{"fi_FI"}), true))
+	require.JSONEq(t, `[]`, buf.String())
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+// replaceExtension replaces the extension of filename with ext
+func replaceExtension(filename, ext string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+}
+
+// readCatalog reads a .po or .xlf-file
+func readCatalog(filename string) (*catalog.Catalog, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cat *catalog.Catalog
+	switch filepath.Ext(filename) {
+	case ".xlf", ".xliff":
+		cat, err = catalog.ParseXLIFF(data)
+	default:
+		cat, err = catalog.ParsePo(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return cat, nil
+}
+
+// importTranslations copies the translations in xlf to po. Messages that are not translated in xlf are left unchanged,
+// and messages that are missing in po are added. Comments and references in po are kept.
+// The number of updated messages is returned.
+func importTranslations(po, xlf *catalog.Catalog) int {
+	updated := 0
+	for _, m := range xlf.Messages {
+		if m.Obsolete || (m.ID == "" && m.Context == "") || !isTranslated(m) {
+			continue
+		}
+
+		existing := po.Lookup(m.Context, m.ID)
+		if existing == nil {
+			po.Add(m)
+			updated++
+			continue
+		}
+
+		existing.Str = m.Str
+		var flags []string
+		for _, flag := range existing.Flags {
+			if flag != "fuzzy" {
+				flags = append(flags, flag)
+			}
+		}
+		if m.IsFuzzy() {
+			flags = append(flags, "fuzzy")
+		}
+		existing.Flags = flags
+		updated++
+	}
+	return updated
+}
+
+func isTranslated(m *catalog.Message) bool {
+	for _, str := range m.Str {
+		if str != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func exportXLIFF(args []string) error {
+	flags := flag.NewFlagSet("export-xliff", flag.ExitOnError)
+	output := flags.String("o", "", "write the XLIFF-file to `file` (default: the name of the .po-file with the extension .xlf)")
+	version := flags.String("version", "1.2", "the XLIFF `version` to write, '1.2' or '2.0'")
+	sourceLanguage := flags.String("source-language", "en", "the `language` of the msgids")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans export-xliff [flags] <file.po>\n\n")
+		fmt.Fprintf(flags.Output(), "Converts a .po-file to XLIFF, keeping contexts, plural messages, comments and references.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	filename := flags.Arg(0)
+	if *output == "" {
+		*output = replaceExtension(filename, ".xlf")
+	}
+
+	po, err := readCatalog(filename)
+	if err != nil {
+		return err
+	}
+
+	domain := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	var buf bytes.Buffer
+	err = catalog.WriteXLIFF(&buf, po,
+		catalog.WithXLIFFVersion(catalog.XLIFFVersion(*version)),
+		catalog.WithSourceLanguage(*sourceLanguage),
+		catalog.WithOriginal(domain))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0666)
+}
+
+func importXLIFF(args []string) error {
+	flags := flag.NewFlagSet("import-xliff", flag.ExitOnError)
+	output := flags.String("o", "", "write the translations to `file` (default: the name of the XLIFF-file with the extension .po)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pongo-trans import-xliff [flags] <file.xlf>\n\n")
+		fmt.Fprintf(flags.Output(), "Copies the translations in an XLIFF-file to a .po-file. If the .po-file exists, only the translations\n")
+		fmt.Fprintf(flags.Output(), "are updated, and the header and comments are kept.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	filename := flags.Arg(0)
+	if *output == "" {
+		*output = replaceExtension(filename, ".po")
+	}
+
+	xlf, err := readCatalog(filename)
+	if err != nil {
+		return err
+	}
+
+	po := xlf
+	if _, err = os.Stat(*output); err == nil {
+		if po, err = readCatalog(*output); err != nil {
+			return err
+		}
+		n := importTranslations(po, xlf)
+		fmt.Fprintf(os.Stderr, "updated %d messages in %s\n", n, *output)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = catalog.WritePo(&buf, po); err != nil {
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0666)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestImportTranslations(t *testing.T) {
+	po, err := catalog.ParsePo([]byte(`msgid ""
+msgstr "Language: sv_SE\n"
+
+# Translator comment
+#: index.html:1
+msgid "Hello world!"
+msgstr ""
+
+#, fuzzy, python-format
+msgid "Hello %s"
+msgstr "Hej"
+
+msgid "Keep"
+msgstr "Behåll"
+`))
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	require.Nil(t, catalog.WriteXLIFF(&buf, po))
+
+	// The agency translates the messages
+	xlf := bytes.Replace(buf.Bytes(), []byte("<source>Hello world!</source>"),
+		[]byte(`<source>Hello world!</source><target state="translated">Hej världen!</target>`), 1)
+	xlf = bytes.Replace(xlf, []byte(`<target state="needs-review-translation">Hej</target>`),
+		[]byte(`<target state="final">Hej %s</target>`), 1)
+	xlf = bytes.Replace(xlf, []byte(`<target state="translated">Behåll</target>`), nil, 1)
+
+	translated, err := catalog.ParseXLIFF(xlf)
+	require.Nil(t, err)
+	require.Equal(t, 2, importTranslations(po, translated))
+
+	buf.Reset()
+	require.Nil(t, catalog.WritePo(&buf, po))
+	require.Equal(t, `msgid ""
+msgstr "Language: sv_SE\n"
+
+# Translator comment
+#: index.html:1
+msgid "Hello world!"
+msgstr "Hej världen!"
+
+#, python-format
+msgid "Hello %s"
+msgstr "Hej %s"
+
+msgid "Keep"
+msgstr "Behåll"
+`, buf.String())
+}
@@ -0,0 +1,724 @@
+package trans
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/yzzyx/pongo-trans/catalog"
+	"golang.org/x/text/language"
+)
+
+// maxFluentDepth is the maximum number of nested message and term references, to protect against cyclic references
+const maxFluentDepth = 10
+
+// FluentTranslator reads Project Fluent resources (.ftl), and interprets the translations as Fluent patterns.
+// The resources are read from the same layout as NewTemplateTranslator uses, e.g. 'locales/sv_SE/default.ftl',
+// see catalog.ParseFluent for how messages, terms and attributes are mapped to msgids and contexts:
+//
+//	ft, err := trans.NewFluentTranslator(localeFS, "locales")
+//	pongo2.RegisterTag("trans", trans.NewTransTag(ft))
+//	pongo2.RegisterTag("blocktrans", trans.NewBlockTransTag(ft))
+//
+//	// and then, in your templates, where the msgid is the id of the message
+//	{% trans "emails" with unread=user.unread %}
+//	{% trans "login-input" context "placeholder" %}
+//
+// Variables, e.g. '{ $unread }', are read from TransCtx.Args, which the 'trans' and 'blocktrans' tags fill
+// with the variables bound with 'with' and 'count'. When a variable is used as a placeable, it's written as a
+// template variable ('{{ unread }}'), so that it's escaped like any other variable when the translation is rendered.
+//
+// Select expressions choose the variant matching the value of the selector. Numbers match variants with the same
+// number, or the CLDR plural category of the number (e.g. '[one]') in the current language.
+// The default variant (e.g. '*[other]') is used if no variant matches, or if the variable is missing.
+//
+// Messages (e.g. '{ menu-save }') and terms (e.g. '{ -brand-name }' or '{ -brand-name(case: "genitive") }')
+// are looked up in the resources, so they follow the same fallbacks as other messages.
+// Functions, such as 'NUMBER($count)', return their first argument unchanged.
+// Translations that aren't valid Fluent patterns, or that reference unknown messages, are returned unchanged,
+// so a template variable such as '{{ name }}' isn't read as a reference to the message 'name'.
+// Messages that are missing from the resources are returned unchanged as well, without being formatted.
+//
+// The resources are loaded by an embedded TemplateTranslator, so they can be reloaded with Reload and Watch,
+// and the statistics are available from Stats.
+type FluentTranslator struct {
+	*TemplateTranslator
+}
+
+// NewFluentTranslator creates a translator that reads the Fluent resources in localePath.
+// The options are the same as for NewTemplateTranslator, e.g. WithFallback, but only .ftl-files are read.
+func NewFluentTranslator(localeFS fs.FS, localePath string, options ...TranslatorOption) (*FluentTranslator, error) {
+	options = append(options, WithCatalogExtensions(".ftl"))
+	t, err := NewTemplateTranslator(localeFS, localePath, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FluentTranslator{
+		TemplateTranslator: t,
+	}, nil
+}
+
+// Get translates a message
+func (t *FluentTranslator) Get(ctx TransCtx, str string, values ...interface{}) string {
+	return printf(t.translate(ctx, str, ""), values...)
+}
+
+// GetC translates an attribute of a message, where transCtx is the name of the attribute
+func (t *FluentTranslator) GetC(ctx TransCtx, str string, transCtx string, values ...interface{}) string {
+	return printf(t.translate(ctx, str, transCtx), values...)
+}
+
+// GetN translates a message. Plurals are handled by the message itself, so plural is not used.
+// The count is available as the variable '$count', unless the tag binds a variable with the same name.
+func (t *FluentTranslator) GetN(ctx TransCtx, str string, plural string, count int, values ...interface{}) string {
+	return printf(t.translate(withCountArg(ctx, count), str, ""), values...)
+}
+
+// GetNC translates an attribute of a message, where transCtx is the name of the attribute.
+// Plurals are handled by the message itself, so plural is not used.
+// The count is available as the variable '$count', unless the tag binds a variable with the same name.
+func (t *FluentTranslator) GetNC(ctx TransCtx, str string, plural string, count int, transCtx string, values ...interface{}) string {
+	return printf(t.translate(withCountArg(ctx, count), str, transCtx), values...)
+}
+
+// translate formats a message or an attribute of it. Messages that are missing are returned unchanged,
+// since an untranslated msgid such as 'Hello {{ name }}' isn't meant to be read as a Fluent pattern
+func (t *FluentTranslator) translate(ctx TransCtx, id string, attribute string) string {
+	if id == "" {
+		return ""
+	}
+
+	pattern, ok := t.pattern(ctx, id, attribute)
+	if !ok {
+		t.missing(ctx, attribute, id, "")
+		return id
+	}
+	return t.format(ctx, pattern)
+}
+
+// fluentStats counts the messages of a Fluent resource. Terms (e.g. '-brand-name') and attributes are not counted,
+// since they're only used by messages, or together with them
+func fluentStats(cat *catalog.Catalog) catalog.Stats {
+	return cat.StatsFunc(func(m *catalog.Message) bool {
+		return m.Context == "" && !strings.HasPrefix(m.ID, "-")
+	})
+}
+
+// pattern returns the pattern of a message or an attribute, using the fallbacks of the translator
+func (t *FluentTranslator) pattern(ctx TransCtx, id string, attribute string) (string, bool) {
+	return t.lookup(ctx, func(cat *catalog.Catalog) (string, bool) {
+		return cat.Translation(attribute, id)
+	})
+}
+
+// SelectsVariants returns true, since the variants of 'select' are chosen by the translations, using TransCtx.Args
+func (t *FluentTranslator) SelectsVariants() bool {
+	return true
+}
+
+// format formats pattern, or returns it unchanged if it isn't a valid Fluent pattern
+func (t *FluentTranslator) format(ctx TransCtx, pattern string) string {
+	tag, err := parseLanguage(ctx.Language)
+	if err != nil {
+		tag = language.Und
+	}
+
+	f := &fluentFormatter{translator: t, ctx: ctx, tag: tag}
+	formatted, err := f.format(pattern, fluentScope{args: ctx.Args}, 0)
+	if err != nil {
+		return pattern
+	}
+	return formatted
+}
+
+// fluentFormatter resolves Fluent patterns to templates
+type fluentFormatter struct {
+	translator *FluentTranslator
+	ctx        TransCtx
+	tag        language.Tag
+}
+
+// fluentScope contains the variables available to a pattern.
+// Messages use the variables bound by the tags, while terms only see the arguments passed to them
+type fluentScope struct {
+	args map[string]interface{}
+	term map[string]fluentValue
+}
+
+// fluentValue is the result of resolving an expression
+type fluentValue struct {
+	template string // The value, as it's written to the template
+	str      string // The value used to select variants
+	num      float64
+	isNum    bool
+}
+
+// format parses and resolves pattern with the variables in args
+func (f *fluentFormatter) format(pattern string, scope fluentScope, depth int) (string, error) {
+	if depth > maxFluentDepth {
+		return "", fmt.Errorf("too many nested references")
+	}
+
+	p := &fluentPatternParser{str: pattern}
+	elements, err := p.pattern(false)
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.str) {
+		return "", p.errorf("unexpected '}'")
+	}
+
+	var sb strings.Builder
+	if err = f.writePattern(&sb, elements, scope, depth); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (f *fluentFormatter) writePattern(sb *strings.Builder, elements []fluentElement, scope fluentScope, depth int) error {
+	for _, element := range elements {
+		if element.expr == nil {
+			sb.WriteString(escapeTemplateText(element.text))
+			continue
+		}
+
+		value, err := f.resolve(element.expr, scope, depth)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(value.template)
+	}
+	return nil
+}
+
+// resolve resolves an expression, including select expressions
+func (f *fluentFormatter) resolve(expr *fluentExpression, scope fluentScope, depth int) (fluentValue, error) {
+	var value fluentValue
+	switch expr.typ {
+	case fluentString:
+		value = fluentValue{template: escapeTemplateText(expr.value), str: expr.value}
+
+	case fluentNumber:
+		n, _ := strconv.ParseFloat(expr.value, 64)
+		value = fluentValue{template: expr.value, str: expr.value, num: n, isNum: true}
+
+	case fluentVariable:
+		missing := fluentValue{template: escapeTemplateText("{$" + expr.value + "}")}
+		if scope.term != nil {
+			var ok bool
+			if value, ok = scope.term[expr.value]; !ok {
+				value = missing
+			}
+			break
+		}
+
+		// Variables that aren't bound by the tag, including names that aren't valid template variables,
+		// are written as in the pattern, since they would otherwise be rendered as empty strings
+		arg, ok := scope.args[expr.value]
+		if !ok || strings.Contains(expr.value, "-") {
+			value = missing
+			break
+		}
+
+		value = fluentValue{template: "{{ " + expr.value + " }}"}
+		if arg != nil {
+			value.str = pongo2.AsValue(arg).String()
+			value.num, value.isNum = messageNumber(arg)
+			if _, ok = arg.(countArg); ok {
+				value.template = value.str
+			}
+		}
+
+	case fluentFunction:
+		if len(expr.args) == 0 {
+			return value, fmt.Errorf("the function %s has no arguments", expr.value)
+		}
+
+		var err error
+		if value, err = f.resolve(&expr.args[0], scope, depth); err != nil {
+			return value, err
+		}
+
+	case fluentMessage, fluentTerm:
+		// References to unknown messages make the pattern invalid, e.g. '{{ name }}', which is a nested
+		// placeable referencing the message 'name' in Fluent, but a template variable in a msgid
+		pattern, ok := f.translator.pattern(f.ctx, expr.value, expr.attribute)
+		if !ok {
+			return value, fmt.Errorf("unknown message '%s'", expr.value)
+		}
+
+		// Terms only see the arguments passed to them
+		termScope := scope
+		if expr.typ == fluentTerm {
+			termScope = fluentScope{term: map[string]fluentValue{}}
+			for name, arg := range expr.named {
+				v, err := f.resolve(&arg, scope, depth+1)
+				if err != nil {
+					return value, err
+				}
+				termScope.term[name] = v
+			}
+		}
+
+		formatted, err := f.format(pattern, termScope, depth+1)
+		if err != nil {
+			return value, err
+		}
+		value = fluentValue{template: formatted, str: formatted}
+	}
+
+	if expr.variants == nil {
+		return value, nil
+	}
+
+	variant := f.selectVariant(expr.variants, value)
+	var sb strings.Builder
+	if err := f.writePattern(&sb, variant, scope, depth); err != nil {
+		return value, err
+	}
+	return fluentValue{template: sb.String(), str: sb.String()}, nil
+}
+
+// selectVariant returns the pattern of the variant matching value, or the default variant
+func (f *fluentFormatter) selectVariant(variants []fluentVariant, value fluentValue) []fluentElement {
+	var category string
+	if value.isNum {
+		category = pluralCategory(f.tag, value.num, false)
+	}
+
+	var def []fluentElement
+	for _, v := range variants {
+		if v.def {
+			def = v.pattern
+		}
+
+		if n, err := strconv.ParseFloat(v.key, 64); err == nil {
+			if value.isNum && n == value.num {
+				return v.pattern
+			}
+		} else if v.key == value.str && value.str != "" {
+			return v.pattern
+		}
+	}
+
+	// Exact matches take precedence over plural categories
+	for _, v := range variants {
+		if category != "" && v.key == category {
+			return v.pattern
+		}
+	}
+	return def
+}
+
+type fluentExpressionType int
+
+const (
+	fluentString fluentExpressionType = iota
+	fluentNumber
+	fluentVariable
+	fluentMessage
+	fluentTerm
+	fluentFunction
+)
+
+// fluentElement is text, or a placeable, in a pattern
+type fluentElement struct {
+	text string
+	expr *fluentExpression
+}
+
+// fluentExpression is an expression in a placeable. Select expressions have a selector and variants
+type fluentExpression struct {
+	typ       fluentExpressionType
+	value     string // The string, number, or the name of the variable, message, term or function
+	attribute string
+
+	args  []fluentExpression // Positional arguments of functions
+	named map[string]fluentExpression
+
+	variants []fluentVariant
+}
+
+// fluentVariant is a variant of a select expression, e.g. '*[other] { $count } files'
+type fluentVariant struct {
+	key     string
+	def     bool
+	pattern []fluentElement
+}
+
+// fluentPatternParser parses the patterns of Fluent messages
+type fluentPatternParser struct {
+	str string
+	pos int
+}
+
+func (p *fluentPatternParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid fluent pattern at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// pattern parses text and placeables, until an unmatched '}' or the end of the string.
+// The pattern of a variant also ends at a line starting a new variant
+func (p *fluentPatternParser) pattern(variant bool) ([]fluentElement, error) {
+	var elements []fluentElement
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			elements = append(elements, fluentElement{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		if c == '}' || (variant && c == '\n' && p.variantEnds()) {
+			break
+		}
+
+		if c == '{' {
+			flush()
+			p.pos++
+			expr, err := p.placeable()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, fluentElement{expr: expr})
+			continue
+		}
+
+		text.WriteByte(c)
+		p.pos++
+	}
+	flush()
+
+	if variant {
+		elements = dedentVariant(elements)
+	}
+	return elements, nil
+}
+
+// variantEnds checks if the line following the current position starts a new variant, or ends the select expression
+func (p *fluentPatternParser) variantEnds() bool {
+	rest := strings.TrimLeft(p.str[p.pos:], " \t\n")
+	return rest == "" || rest[0] == '[' || rest[0] == '*' || rest[0] == '}'
+}
+
+// dedentVariant removes the whitespace surrounding the pattern of a variant,
+// and the common indentation of its lines
+func dedentVariant(elements []fluentElement) []fluentElement {
+	if len(elements) == 0 {
+		return elements
+	}
+
+	if first := &elements[0]; first.expr == nil {
+		first.text = strings.TrimLeft(first.text, " \t\n")
+	}
+	if last := &elements[len(elements)-1]; last.expr == nil {
+		last.text = strings.TrimRight(last.text, " \t\n")
+	}
+
+	indent := -1
+	for _, element := range elements {
+		lines := strings.Split(element.text, "\n")
+		for _, line := range lines[1:] {
+			if n := len(line) - len(strings.TrimLeft(line, " \t")); line != "" && (indent < 0 || n < indent) {
+				indent = n
+			}
+		}
+	}
+
+	if indent > 0 {
+		for k := range elements {
+			elements[k].text = strings.Replace(elements[k].text, "\n"+strings.Repeat(" ", indent), "\n", -1)
+		}
+	}
+	return elements
+}
+
+// placeable parses a placeable, after the opening brace
+func (p *fluentPatternParser) placeable() (*fluentExpression, error) {
+	p.skipBlank()
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if strings.HasPrefix(p.str[p.pos:], "->") {
+		p.pos += 2
+		if expr.variants, err = p.variants(); err != nil {
+			return nil, err
+		}
+		p.skipBlank()
+	}
+
+	if !p.consume('}') {
+		return nil, p.errorf("expected '}'")
+	}
+	return expr, nil
+}
+
+// variants parses the variants of a select expression
+func (p *fluentPatternParser) variants() ([]fluentVariant, error) {
+	var variants []fluentVariant
+	defaults := 0
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.str) || p.str[p.pos] == '}' {
+			break
+		}
+
+		v := fluentVariant{def: p.consume('*')}
+		if !p.consume('[') {
+			return nil, p.errorf("expected a variant")
+		}
+
+		p.skipBlank()
+		if v.key = p.number(); v.key == "" {
+			v.key = p.identifier()
+		}
+		if v.key == "" {
+			return nil, p.errorf("expected a variant key")
+		}
+
+		p.skipBlank()
+		if !p.consume(']') {
+			return nil, p.errorf("expected ']'")
+		}
+
+		var err error
+		if v.pattern, err = p.pattern(true); err != nil {
+			return nil, err
+		}
+
+		if v.def {
+			defaults++
+		}
+		variants = append(variants, v)
+	}
+
+	if defaults != 1 {
+		return nil, p.errorf("a select expression must have exactly one default variant")
+	}
+	return variants, nil
+}
+
+// expression parses an inline expression
+func (p *fluentPatternParser) expression() (*fluentExpression, error) {
+	if p.pos >= len(p.str) {
+		return nil, p.errorf("expected an expression")
+	}
+
+	switch c := p.str[p.pos]; {
+	case c == '"':
+		return p.stringLiteral()
+
+	case c == '$':
+		p.pos++
+		name := p.identifier()
+		if name == "" {
+			return nil, p.errorf("expected a variable name")
+		}
+		return &fluentExpression{typ: fluentVariable, value: name}, nil
+
+	case c == '{':
+		p.pos++
+		return p.placeable()
+
+	case c == '-' || (c >= '0' && c <= '9'):
+		if n := p.number(); n != "" {
+			return &fluentExpression{typ: fluentNumber, value: n}, nil
+		}
+
+		p.pos++
+		name := p.identifier()
+		if name == "" {
+			return nil, p.errorf("expected a term name")
+		}
+		expr := &fluentExpression{typ: fluentTerm, value: "-" + name, attribute: p.attribute()}
+
+		p.skipBlank()
+		if p.consume('(') {
+			if err := p.arguments(expr); err != nil {
+				return nil, err
+			}
+		}
+		return expr, nil
+	}
+
+	name := p.identifier()
+	if name == "" {
+		return nil, p.errorf("expected an expression")
+	}
+
+	if p.consume('(') {
+		expr := &fluentExpression{typ: fluentFunction, value: name}
+		return expr, p.arguments(expr)
+	}
+	return &fluentExpression{typ: fluentMessage, value: name, attribute: p.attribute()}, nil
+}
+
+// attribute parses the name of an attribute, e.g. '.gender', if there is one
+func (p *fluentPatternParser) attribute() string {
+	if p.pos+1 < len(p.str) && p.str[p.pos] == '.' && isFluentLetter(p.str[p.pos+1]) {
+		p.pos++
+		return p.identifier()
+	}
+	return ""
+}
+
+// arguments parses the arguments of a function or a term, after the opening parenthesis
+func (p *fluentPatternParser) arguments(expr *fluentExpression) error {
+	for {
+		p.skipBlank()
+		if p.consume(')') {
+			return nil
+		}
+
+		start := p.pos
+		if name := p.identifier(); name != "" {
+			p.skipBlank()
+			if p.consume(':') {
+				p.skipBlank()
+				arg, err := p.expression()
+				if err != nil {
+					return err
+				}
+				if arg.typ != fluentString && arg.typ != fluentNumber {
+					return p.errorf("the value of the named argument '%s' must be a literal", name)
+				}
+
+				if expr.named == nil {
+					expr.named = map[string]fluentExpression{}
+				}
+				expr.named[name] = *arg
+				p.nextArgument()
+				continue
+			}
+			p.pos = start
+		}
+
+		arg, err := p.expression()
+		if err != nil {
+			return err
+		}
+		expr.args = append(expr.args, *arg)
+		p.nextArgument()
+	}
+}
+
+// nextArgument skips the comma separating arguments
+func (p *fluentPatternParser) nextArgument() {
+	p.skipBlank()
+	p.consume(',')
+}
+
+// stringLiteral parses a quoted string, with the escape sequences '\"', '\\', '\uXXXX' and '\UXXXXXX'
+func (p *fluentPatternParser) stringLiteral() (*fluentExpression, error) {
+	p.pos++ // '"'
+	var sb strings.Builder
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		p.pos++
+
+		switch c {
+		case '"':
+			return &fluentExpression{typ: fluentString, value: sb.String()}, nil
+		case '\n':
+			return nil, p.errorf("unterminated string")
+		case '\\':
+			if p.pos >= len(p.str) {
+				return nil, p.errorf("unterminated string")
+			}
+
+			switch e := p.str[p.pos]; e {
+			case '"', '\\':
+				sb.WriteByte(e)
+				p.pos++
+			case 'u', 'U':
+				n := 4
+				if e == 'U' {
+					n = 6
+				}
+				if p.pos+1+n > len(p.str) {
+					return nil, p.errorf("invalid escape sequence")
+				}
+				r, err := strconv.ParseUint(p.str[p.pos+1:p.pos+1+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return nil, p.errorf("invalid escape sequence")
+				}
+				sb.WriteRune(rune(r))
+				p.pos += 1 + n
+			default:
+				return nil, p.errorf("invalid escape sequence")
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// number parses a number literal, e.g. '-1.5', if there is one
+func (p *fluentPatternParser) number() string {
+	start := p.pos
+	end := start
+	if end < len(p.str) && p.str[end] == '-' {
+		end++
+	}
+
+	digits := end
+	for end < len(p.str) && p.str[end] >= '0' && p.str[end] <= '9' {
+		end++
+	}
+	if end == digits {
+		return ""
+	}
+
+	if end+1 < len(p.str) && p.str[end] == '.' && p.str[end+1] >= '0' && p.str[end+1] <= '9' {
+		end++
+		for end < len(p.str) && p.str[end] >= '0' && p.str[end] <= '9' {
+			end++
+		}
+	}
+
+	p.pos = end
+	return p.str[start:end]
+}
+
+// identifier parses a name, e.g. of a variable or a message
+func (p *fluentPatternParser) identifier() string {
+	start := p.pos
+	if p.pos >= len(p.str) || !isFluentLetter(p.str[p.pos]) {
+		return ""
+	}
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		if !isFluentLetter(c) && (c < '0' || c > '9') && c != '_' && c != '-' {
+			break
+		}
+		p.pos++
+	}
+	return p.str[start:p.pos]
+}
+
+func isFluentLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (p *fluentPatternParser) consume(c byte) bool {
+	if p.pos < len(p.str) && p.str[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *fluentPatternParser) skipBlank() {
+	for p.pos < len(p.str) && isMessageSpace(p.str[p.pos]) {
+		p.pos++
+	}
+}
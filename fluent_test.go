@@ -0,0 +1,163 @@
+package trans
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestFluentTranslator(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/ru/default.ftl": &fstest.MapFile{Data: []byte(`-brand-name =
+    { $case ->
+       *[nominative] Файрфокс
+        [genitive] Файрфокса
+    }
+    .gender = masculine
+
+hello-user = Привет, { $name }!
+about = О программе { -brand-name(case: "genitive") }
+brand-gender = { -brand-name.gender ->
+        [masculine] Он
+       *[other] Оно
+    }
+
+emails =
+    { NUMBER($unread) ->
+        [0] Нет новых писем
+        [one] { $unread } новое письмо
+        [few] { $unread } новых письма
+       *[many] { $unread } новых писем
+    }
+
+shared = { $gender ->
+        [female] { $name } поделилась { emails }
+       *[other] { $name } поделился { emails }
+    }
+
+files = { $count ->
+        [one] { $count } файл
+       *[other] { $count } файла
+    }
+
+login-input = Логин
+    .placeholder = email@example.com
+
+braces = Use {"{"} and {"}"} for { "placeables" }
+invalid = { $unread -> [one] one }
+cycle = { cycle }
+nested = Hej {{ name }}
+nested-term = { { -brand-name } }
+unknown = Откройте { settings-menu }
+`)},
+	}
+
+	ft, err := NewFluentTranslator(localeFS, "locales")
+	require.Nil(t, err)
+
+	tests := []struct {
+		id       string
+		context  string
+		args     map[string]interface{}
+		expected string
+	}{
+		{id: "hello-user", args: map[string]interface{}{"name": "Ivan"}, expected: "Привет, {{ name }}!"},
+		{id: "hello-user", expected: `Привет, {{ "{" }}$name}!`},
+		{id: "about", expected: "О программе Файрфокса"},
+		{id: "-brand-name", expected: "Файрфокс"},
+		{id: "brand-gender", expected: "Он"},
+		{id: "emails", args: map[string]interface{}{"unread": 0}, expected: "Нет новых писем"},
+		{id: "emails", args: map[string]interface{}{"unread": 21}, expected: "{{ unread }} новое письмо"},
+		{id: "emails", args: map[string]interface{}{"unread": "3"}, expected: "{{ unread }} новых письма"},
+		{id: "emails", args: map[string]interface{}{"unread": 5}, expected: "{{ unread }} новых писем"},
+		{id: "emails", expected: `{{ "{" }}$unread} новых писем`},
+		{id: "shared", args: map[string]interface{}{"gender": "female", "name": "Anna", "unread": 1}, expected: "{{ name }} поделилась {{ unread }} новое письмо"},
+		{id: "shared", args: map[string]interface{}{"unread": 1}, expected: `{{ "{" }}$name} поделился {{ unread }} новое письмо`},
+		{id: "login-input", context: "placeholder", expected: "email@example.com"},
+		{id: "braces", expected: `Use {{ "{" }} and } for placeables`},
+		{id: "invalid", expected: "{ $unread -> [one] one }"},
+		{id: "cycle", expected: "{ cycle }"},
+		{id: "Untranslated", expected: "Untranslated"},
+		{id: "nested", expected: "Hej {{ name }}"},
+		{id: "nested-term", expected: "Файрфокс"},
+		{id: "unknown", expected: "Откройте { settings-menu }"},
+		{id: "Hello {{ name }}", expected: "Hello {{ name }}"},
+	}
+
+	for k, tst := range tests {
+		ctx := TransCtx{Language: "ru", Args: tst.args}
+		var result string
+		if tst.context != "" {
+			result = ft.GetC(ctx, tst.id, tst.context)
+		} else {
+			result = ft.Get(ctx, tst.id)
+		}
+		require.Equalf(t, tst.expected, result, "test: %d, id: %s", k, tst.id)
+	}
+
+	// The count is available as a variable
+	require.Equal(t, "2 файла", ft.GetN(TransCtx{Language: "ru"}, "files", "files", 2))
+	require.Equal(t, "ru", ft.LanguageInfo("ru").Code)
+	require.Len(t, ft.Languages(), 1)
+
+	// Terms and attributes are not counted as messages of their own
+	stats := ft.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, catalog.Stats{Total: 13, Translated: 13}, stats[0].Stats)
+
+	err = pongo2.RegisterTag("trans", NewTransTag(ft))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(ft))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(ft))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(ft))
+	}
+	require.Nil(t, err)
+
+	tmpl, err := pongo2.FromString(`{% trans "hello-user" with name=user %}|` +
+		`{% trans "Hello {{ name }}" with name=user %}|` +
+		`{% trans "login-input" context "placeholder" %}|` +
+		`{% blocktrans with gender="female" name=user count unread=3 %}shared{% endblocktrans %}|` +
+		`{% trans "emails" %}`)
+	require.Nil(t, err)
+	result, err := tmpl.Execute(pongo2.Context{"_language": "ru", "user": "<b>"})
+	require.Nil(t, err)
+	require.Equal(t, "Привет, &lt;b&gt;!|Hello &lt;b&gt;|email@example.com|&lt;b&gt; поделилась 3 новых письма|{$unread} новых писем", result)
+}
+
+func TestNewFluentTranslator(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.ftl": &fstest.MapFile{Data: []byte("hello = Hej från Fluent!\n")},
+		"locales/sv_SE/default.po":  testCatalog("Hej från gettext!"),
+	}
+
+	// Only the Fluent resources are read by the FluentTranslator, and only the catalogs by the TemplateTranslator
+	ft, err := NewFluentTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	require.Equal(t, "Hej från Fluent!", ft.Get(TransCtx{Language: "sv_SE"}, "hello"))
+	require.Equal(t, "Hello world!", ft.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	require.Equal(t, "hello", tt.Get(TransCtx{Language: "sv_SE"}, "hello"))
+	require.Equal(t, "Hej från gettext!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	// The resources can be reloaded
+	localeFS["locales/sv_SE/default.ftl"] = &fstest.MapFile{Data: []byte("hello = Hallå från Fluent!\n")}
+	require.Nil(t, ft.Reload(nil))
+	require.Equal(t, "Hallå från Fluent!", ft.Get(TransCtx{Language: "sv_SE"}, "hello"))
+
+	// Invalid resources are logged and skipped when the translator is created
+	localeFS["locales/sv_SE/default.ftl"] = &fstest.MapFile{Data: []byte("hello = { \n")}
+	logged := captureLog(t)
+	ft, err = NewFluentTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	require.Contains(t, logged.String(), "locales/sv_SE/default.ftl")
+	require.Equal(t, "hello", ft.Get(TransCtx{Language: "sv_SE"}, "hello"))
+}
@@ -6,4 +6,5 @@ require (
 	github.com/flosch/pongo2/v6 v6.0.0
 	github.com/leonelquinteros/gotext v1.5.1
 	github.com/stretchr/testify v1.8.1
+	golang.org/x/text v0.3.7
 )
@@ -0,0 +1,500 @@
+package trans
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// ICUTranslator wraps a Translator, and interprets the translations as ICU MessageFormat, e.g.
+// '{count, plural, =0 {No files} one {# file} other {# files}}' or '{gender, select, female {her} male {his} other {their}}'.
+//
+// The values of plural and select arguments are read from TransCtx.Args,
+// which the 'trans' and 'blocktrans' tags fill with the variables bound with 'with' and 'count':
+//
+//	t, err := trans.NewTemplateTranslator(localeFS, "locales")
+//	it := trans.NewICUTranslator(t)
+//	pongo2.RegisterTag("trans", trans.NewTransTag(it))
+//
+//	// and then, in your templates
+//	{% trans "{count, plural, one {# file} other {# files}}" with count=files|length %}
+//
+// See FormatMessage for details on how the translations are formatted.
+// Translations that aren't valid MessageFormat, e.g. ordinary gettext translations using '{{ name }}', are returned unchanged.
+type ICUTranslator struct {
+	translator Translator
+}
+
+// NewICUTranslator creates a translator that formats the translations of translator as ICU MessageFormat
+func NewICUTranslator(translator Translator) *ICUTranslator {
+	return &ICUTranslator{
+		translator: translator,
+	}
+}
+
+// Get translates a string
+func (t *ICUTranslator) Get(ctx TransCtx, str string, values ...interface{}) string {
+	return printf(t.format(ctx, t.translator.Get(ctx, str)), values...)
+}
+
+// GetC translates a string, with a specific translation context
+func (t *ICUTranslator) GetC(ctx TransCtx, str string, transCtx string, values ...interface{}) string {
+	return printf(t.format(ctx, t.translator.GetC(ctx, str, transCtx)), values...)
+}
+
+// GetN translates a string, with support for plurals.
+// The count is available to the message as the argument 'count', unless the tag binds a variable with the same name.
+func (t *ICUTranslator) GetN(ctx TransCtx, str string, plural string, count int, values ...interface{}) string {
+	return printf(t.format(withCountArg(ctx, count), t.translator.GetN(ctx, str, plural, count)), values...)
+}
+
+// GetNC translates a string, with a specific translation context, with support for plurals.
+// The count is available to the message as the argument 'count', unless the tag binds a variable with the same name.
+func (t *ICUTranslator) GetNC(ctx TransCtx, str string, plural string, count int, transCtx string, values ...interface{}) string {
+	return printf(t.format(withCountArg(ctx, count), t.translator.GetNC(ctx, str, plural, count, transCtx)), values...)
+}
+
+// SelectsVariants returns true, since the variants of 'select' are chosen by the translations, using TransCtx.Args
+func (t *ICUTranslator) SelectsVariants() bool {
+	return true
+}
+
+// Languages returns the languages of the wrapped translator, if it's a LanguageProvider
+func (t *ICUTranslator) Languages() []LanguageInfo {
+	if provider, ok := t.translator.(LanguageProvider); ok {
+		return provider.Languages()
+	}
+	return nil
+}
+
+// LanguageInfo returns information about a language
+func (t *ICUTranslator) LanguageInfo(code string) LanguageInfo {
+	if provider, ok := t.translator.(LanguageProvider); ok {
+		return provider.LanguageInfo(code)
+	}
+	return newLanguageInfo(code)
+}
+
+// OnReload registers a function that is called every time the translations of the wrapped translator are reloaded
+func (t *ICUTranslator) OnReload(fn func()) {
+	if notifier, ok := t.translator.(ReloadNotifier); ok {
+		notifier.OnReload(fn)
+	}
+}
+
+// format formats message, or returns it unchanged if it isn't valid MessageFormat
+func (t *ICUTranslator) format(ctx TransCtx, message string) string {
+	formatted, err := FormatMessage(ctx, message)
+	if err != nil {
+		return message
+	}
+	return formatted
+}
+
+// countArg is the count added to the arguments by withCountArg. Since it isn't bound by the tag,
+// and thus isn't available when the translation is rendered, it's written as is instead of as a template variable
+type countArg int
+
+// withCountArg adds count to the arguments of ctx, unless an argument named 'count' already exists
+func withCountArg(ctx TransCtx, count int) TransCtx {
+	if _, ok := ctx.Args["count"]; ok {
+		return ctx
+	}
+
+	args := make(map[string]interface{}, len(ctx.Args)+1)
+	for name, value := range ctx.Args {
+		args[name] = value
+	}
+	args["count"] = countArg(count)
+	ctx.Args = args
+	return ctx
+}
+
+// FormatMessage formats an ICU MessageFormat message, with the arguments in ctx.Args.
+//
+// The result is meant to be rendered as a template by the 'trans' and 'blocktrans' tags:
+// simple arguments, e.g. '{name}' or '{price, number}', are written as template variables ('{{ name }}'),
+// so that they are escaped like any other variable. Braces in the text of the message are written as '{{ "{" }}'.
+// The count added by GetN and GetNC isn't bound by the tags, so it's written as a number instead.
+//
+// Plural and selectordinal arguments choose a message with the CLDR plural rules of ctx.Language,
+// after looking for an exact match (e.g. '=0'), and '#' is replaced by the number minus the offset, if any.
+// Select arguments choose the message matching the value of the argument.
+// The message 'other' is used for arguments that are missing from ctx.Args,
+// and for plural arguments that aren't numbers.
+//
+// Apostrophes quote special characters as in ICU, e.g. "'{'" is written as '{', and two apostrophes as one.
+// An error is returned if message isn't valid MessageFormat.
+func FormatMessage(ctx TransCtx, message string) (string, error) {
+	parts, err := parseMessageFormat(message)
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := parseLanguage(ctx.Language)
+	if err != nil {
+		tag = language.Und
+	}
+
+	var sb strings.Builder
+	formatMessageParts(&sb, tag, ctx.Args, parts, "")
+	return sb.String(), nil
+}
+
+// messagePart is a part of a parsed MessageFormat message: literal text, a '#' or an argument
+type messagePart struct {
+	text string
+	hash bool
+
+	arg     string
+	typ     string // 'plural', 'selectordinal', 'select', or the type of a simple argument, e.g. 'number'
+	offset  float64
+	options []messageOption
+}
+
+// messageOption is a message selected by a plural or select argument, e.g. 'one {# file}'
+type messageOption struct {
+	selector string
+	message  []messagePart
+}
+
+// formatMessageParts writes parts to sb. hash is written for every '#'
+func formatMessageParts(sb *strings.Builder, tag language.Tag, args map[string]interface{}, parts []messagePart, hash string) {
+	for _, part := range parts {
+		switch {
+		case part.hash:
+			sb.WriteString(hash)
+		case part.arg == "":
+			sb.WriteString(escapeTemplateText(part.text))
+		case part.typ == "select":
+			var selectors []string
+			if value, ok := args[part.arg]; ok && value != nil {
+				selectors = append(selectors, pongo2.AsValue(value).String())
+			}
+			formatMessageParts(sb, tag, args, selectOption(part.options, selectors...), hash)
+		case part.typ == "plural" || part.typ == "selectordinal":
+			n, ok := messageNumber(args[part.arg])
+			if !ok {
+				formatMessageParts(sb, tag, args, selectOption(part.options), "{{ "+part.arg+" }}")
+				continue
+			}
+
+			message := selectOption(part.options,
+				"="+strconv.FormatFloat(n, 'f', -1, 64),
+				pluralCategory(tag, n-part.offset, part.typ == "selectordinal"))
+			formatMessageParts(sb, tag, args, message, strconv.FormatFloat(n-part.offset, 'f', -1, 64))
+		default:
+			if count, ok := args[part.arg].(countArg); ok {
+				sb.WriteString(strconv.Itoa(int(count)))
+				continue
+			}
+			sb.WriteString("{{ " + part.arg + " }}")
+		}
+	}
+}
+
+// escapedBrace is how braces in the text of a translation are written, see escapeTemplateText.
+// It's allowed by ValidateTranslation, since it only outputs a string literal
+const escapedBrace = `{{ "{" }}`
+
+// escapeTemplateText escapes the text of a translation, so that it can't be interpreted as template syntax
+func escapeTemplateText(text string) string {
+	return strings.Replace(text, "{", escapedBrace, -1)
+}
+
+// selectOption returns the message of the first option matching one of selectors, or the message of 'other'
+func selectOption(options []messageOption, selectors ...string) []messagePart {
+	for _, selector := range append(selectors, "other") {
+		for _, option := range options {
+			if option.selector == selector {
+				return option.message
+			}
+		}
+	}
+	return nil
+}
+
+// messageNumber converts the value of a plural argument to a number
+func messageNumber(value interface{}) (float64, bool) {
+	if value == nil {
+		return 0, false
+	}
+
+	v := pongo2.AsValue(value)
+	switch {
+	case v.IsNumber():
+		return v.Float(), true
+	case v.IsString():
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// pluralCategories contains the names of the CLDR plural forms
+var pluralCategories = map[plural.Form]string{
+	plural.Other: "other",
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+}
+
+// pluralCategory returns the CLDR plural category of n, e.g. 'one'
+func pluralCategory(tag language.Tag, n float64, ordinal bool) string {
+	digits := strconv.FormatFloat(math.Abs(n), 'f', -1, 64)
+	integer, fraction := digits, ""
+	if k := strings.IndexByte(digits, '.'); k >= 0 {
+		integer, fraction = digits[:k], digits[k+1:]
+	}
+
+	// The rules only depend on the last few digits of large numbers
+	if len(integer) > 7 {
+		integer = integer[len(integer)-7:]
+	}
+	i, _ := strconv.Atoi(integer)
+	f, _ := strconv.Atoi(fraction)
+
+	rules := plural.Cardinal
+	if ordinal {
+		rules = plural.Ordinal
+	}
+	return pluralCategories[rules.MatchPlural(tag, i, len(fraction), len(fraction), f, f)]
+}
+
+// messageParser parses ICU MessageFormat messages
+type messageParser struct {
+	str string
+	pos int
+}
+
+// parseMessageFormat parses an ICU MessageFormat message
+func parseMessageFormat(str string) ([]messagePart, error) {
+	p := &messageParser{str: str}
+	parts, err := p.message(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.str) {
+		return nil, p.errorf("unexpected '}'")
+	}
+	return parts, nil
+}
+
+func (p *messageParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid message format at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// message parses a message until the next unquoted '}' or the end of the string.
+// '#' is only special in the messages of plural arguments
+func (p *messageParser) message(inPlural bool) ([]messagePart, error) {
+	var parts []messagePart
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, messagePart{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.str) {
+		switch c := p.str[p.pos]; {
+		case c == '}':
+			flush()
+			return parts, nil
+		case c == '{':
+			flush()
+			part, err := p.argument()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		case c == '#' && inPlural:
+			flush()
+			parts = append(parts, messagePart{hash: true})
+			p.pos++
+		case c == '\'':
+			p.quote(&text, inPlural)
+		default:
+			text.WriteByte(c)
+			p.pos++
+		}
+	}
+	flush()
+	return parts, nil
+}
+
+// quote parses an apostrophe. Two apostrophes are written as one, and an apostrophe followed by a special character
+// starts quoted text, which ends at the next single apostrophe. Other apostrophes are written as is
+func (p *messageParser) quote(text *strings.Builder, inPlural bool) {
+	p.pos++
+	if p.pos >= len(p.str) {
+		text.WriteByte('\'')
+		return
+	}
+
+	switch c := p.str[p.pos]; {
+	case c == '\'':
+		text.WriteByte('\'')
+		p.pos++
+		return
+	case c == '{' || c == '}' || c == '|' || (c == '#' && inPlural):
+	default:
+		text.WriteByte('\'')
+		return
+	}
+
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		p.pos++
+		if c != '\'' {
+			text.WriteByte(c)
+			continue
+		}
+		if p.pos < len(p.str) && p.str[p.pos] == '\'' {
+			text.WriteByte('\'')
+			p.pos++
+			continue
+		}
+		return
+	}
+}
+
+// argument parses an argument, e.g. '{name}', '{price, number, currency}' or '{count, plural, one {...} other {...}}'
+func (p *messageParser) argument() (messagePart, error) {
+	p.pos++ // '{'
+	p.skipSpace()
+
+	part := messagePart{arg: p.identifier()}
+	if part.arg == "" {
+		return part, p.errorf("expected an argument name")
+	}
+
+	p.skipSpace()
+	if p.consume('}') {
+		return part, nil
+	}
+	if !p.consume(',') {
+		return part, p.errorf("expected ',' or '}' after the argument '%s'", part.arg)
+	}
+
+	p.skipSpace()
+	part.typ = p.identifier()
+	if part.typ == "" {
+		return part, p.errorf("expected the type of the argument '%s'", part.arg)
+	}
+	p.skipSpace()
+
+	switch part.typ {
+	case "plural", "selectordinal", "select":
+		if !p.consume(',') {
+			return part, p.errorf("expected ',' after '%s'", part.typ)
+		}
+		if err := p.options(&part); err != nil {
+			return part, err
+		}
+	default:
+		// The style of simple arguments, e.g. 'currency' in '{price, number, currency}', is ignored
+		if p.consume(',') {
+			for p.pos < len(p.str) && p.str[p.pos] != '}' {
+				p.pos++
+			}
+		}
+	}
+
+	if !p.consume('}') {
+		return part, p.errorf("expected '}' to end the argument '%s'", part.arg)
+	}
+	return part, nil
+}
+
+// options parses the options of a plural or select argument, including the offset of plural arguments
+func (p *messageParser) options(part *messagePart) error {
+	p.skipSpace()
+	if part.typ != "select" && strings.HasPrefix(p.str[p.pos:], "offset:") {
+		p.pos += len("offset:")
+		p.skipSpace()
+		start := p.pos
+		for p.pos < len(p.str) && p.str[p.pos] >= '0' && p.str[p.pos] <= '9' {
+			p.pos++
+		}
+
+		offset, err := strconv.ParseFloat(p.str[start:p.pos], 64)
+		if err != nil {
+			return p.errorf("expected a number after 'offset:'")
+		}
+		part.offset = offset
+	}
+
+	hasOther := false
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.str) || p.str[p.pos] == '}' {
+			break
+		}
+
+		start := p.pos
+		for p.pos < len(p.str) && !isMessageSpace(p.str[p.pos]) && p.str[p.pos] != '{' && p.str[p.pos] != '}' {
+			p.pos++
+		}
+		selector := p.str[start:p.pos]
+
+		p.skipSpace()
+		if !p.consume('{') {
+			return p.errorf("expected '{' after the selector '%s'", selector)
+		}
+
+		message, err := p.message(part.typ != "select")
+		if err != nil {
+			return err
+		}
+		if !p.consume('}') {
+			return p.errorf("expected '}' to end the message of '%s'", selector)
+		}
+
+		part.options = append(part.options, messageOption{selector: selector, message: message})
+		hasOther = hasOther || selector == "other"
+	}
+
+	if !hasOther {
+		return p.errorf("the argument '%s' has no 'other' message", part.arg)
+	}
+	return nil
+}
+
+// identifier parses a name, e.g. of an argument. The names must be valid template variables
+func (p *messageParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (p.pos == start || c < '0' || c > '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.str[start:p.pos]
+}
+
+func (p *messageParser) consume(c byte) bool {
+	if p.pos < len(p.str) && p.str[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *messageParser) skipSpace() {
+	for p.pos < len(p.str) && isMessageSpace(p.str[p.pos]) {
+		p.pos++
+	}
+}
+
+func isMessageSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
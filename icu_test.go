@@ -0,0 +1,163 @@
+package trans
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMessage(t *testing.T) {
+	files := "{count, plural, =0 {No files} one {# file} other {# files}}"
+	tests := []struct {
+		language string
+		message  string
+		args     map[string]interface{}
+		expected string
+		err      bool
+	}{
+		{message: "Hello world!", expected: "Hello world!"},
+		{message: "Hello {name}!", expected: "Hello {{ name }}!"},
+		{message: "Costs {price, number, currency}", expected: "Costs {{ price }}"},
+		{language: "en", message: files, args: map[string]interface{}{"count": 0}, expected: "No files"},
+		{language: "en", message: files, args: map[string]interface{}{"count": 1}, expected: "1 file"},
+		{language: "ja", message: files, args: map[string]interface{}{"count": 1}, expected: "1 files"},
+		{language: "en", message: files, args: map[string]interface{}{"count": 21}, expected: "21 files"},
+		{language: "en", message: files, args: map[string]interface{}{"count": "1"}, expected: "1 file"},
+		{language: "en", message: files, args: map[string]interface{}{"count": 1.5}, expected: "1.5 files"},
+		{message: files, expected: "{{ count }} files"},
+		{language: "ru", message: "{n, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}", args: map[string]interface{}{"n": 21}, expected: "21 файл"},
+		{language: "ru", message: "{n, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}", args: map[string]interface{}{"n": 24}, expected: "24 файла"},
+		{language: "ru", message: "{n, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}", args: map[string]interface{}{"n": 25}, expected: "25 файлов"},
+		{language: "ru", message: "{n, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}", args: map[string]interface{}{"n": 2.5}, expected: "2.5 файла"},
+		{language: "en_GB", message: "{n, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}", args: map[string]interface{}{"n": 22}, expected: "22nd"},
+		{language: "en", message: "{n, plural, offset:1 =0 {Nobody} =1 {{name}} one {{name} and # other} other {{name} and # others}}", args: map[string]interface{}{"n": 1}, expected: "{{ name }}"},
+		{language: "en", message: "{n, plural, offset:1 =0 {Nobody} =1 {{name}} one {{name} and # other} other {{name} and # others}}", args: map[string]interface{}{"n": 2}, expected: "{{ name }} and 1 other"},
+		{language: "en", message: "{n, plural, offset:1 =0 {Nobody} =1 {{name}} one {{name} and # other} other {{name} and # others}}", args: map[string]interface{}{"n": 3}, expected: "{{ name }} and 2 others"},
+		{message: "{gender, select, female {her} male {his} other {their}} car", args: map[string]interface{}{"gender": "female"}, expected: "her car"},
+		{message: "{gender, select, female {her} male {his} other {their}} car", args: map[string]interface{}{"gender": "x"}, expected: "their car"},
+		{message: "{gender, select, female {her} male {his} other {their}} car", expected: "their car"},
+		{
+			message:  "{gender, select, female {{n, plural, one {She has # file} other {She has # files}}} other {{n, plural, one {They have # file} other {They have # files}}}}",
+			args:     map[string]interface{}{"gender": "female", "n": 2},
+			expected: "She has 2 files",
+		},
+		{message: "# {n, plural, other {# '#' {x, select, other {#}}}}", args: map[string]interface{}{"n": 3}, expected: "# 3 # #"},
+		{message: "It's '{quoted}' and ''{name}''", expected: `It's {{ "{" }}quoted} and '{{ name }}'`},
+		{message: "Hello {{ name }}", err: true},
+		{message: "Hello {name", err: true},
+		{message: "Hello {1}", err: true},
+		{message: "Hello }", err: true},
+		{message: "{n, plural, one {# file}}", err: true},
+		{message: "{n, plural, one # file other {# files}}", err: true},
+		{message: "{n, plural, offset:x other {# files}}", err: true},
+		{message: "{n, select}", err: true},
+	}
+
+	for k, tst := range tests {
+		result, err := FormatMessage(TransCtx{Language: tst.language, Args: tst.args}, tst.message)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, message: %s", k, tst.message)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, message: %s", k, tst.message)
+		require.Equalf(t, tst.expected, result, "test: %d, message: %s", k, tst.message)
+	}
+}
+
+func TestICUTranslator(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": &fstest.MapFile{Data: []byte(`msgid ""
+msgstr ""
+"Language: sv_SE\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "{count, plural, one {# file} other {# files}}"
+msgstr "{count, plural, =0 {Inga filer} one {# fil} other {# filer}}"
+
+msgid "{gender, select, female {{name} updated her profile} male {{name} updated his profile} other {{name} updated their profile}}"
+msgstr "{gender, select, other {{name} uppdaterade sin profil}}"
+
+msgid "Hello {{ name }}"
+msgstr "Hej {{ name }}"
+
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] "{count} äpple"
+msgstr[1] "{count} äpplen"
+
+msgid "%d pear"
+msgid_plural "%d pears"
+msgstr[0] "{count} päron"
+msgstr[1] "{count} päron, '{n}'"
+`)},
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	it := NewICUTranslator(tt)
+
+	ctx := TransCtx{Language: "sv_SE", Args: map[string]interface{}{"count": 0}}
+	require.Equal(t, "Inga filer", it.Get(ctx, "{count, plural, one {# file} other {# files}}"))
+	// Untranslated messages are formatted as well
+	require.Equal(t, "Hi {{ name }}", it.Get(ctx, "Hi {name}"))
+	// Translations that are not MessageFormat are left unchanged
+	require.Equal(t, "Hej {{ name }}", it.Get(ctx, "Hello {{ name }}"))
+	// The count is available as an argument, and is written as is, since it isn't bound by the tag
+	require.Equal(t, "3 äpplen", it.GetN(TransCtx{Language: "sv_SE"}, "%d apple", "%d apples", 3))
+	require.Equal(t, "sv_SE", it.LanguageInfo("sv_SE").Code)
+	require.Len(t, it.Languages(), 1)
+
+	err = pongo2.RegisterTag("trans", NewTransTag(it))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(it))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(it))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(it))
+	}
+	require.Nil(t, err)
+
+	// Restricted interpolation must allow the count and escaped braces
+	for name, tag := range map[string]pongo2.TagParser{
+		"rtrans":      NewTransTag(it, WithRestrictedInterpolation()),
+		"rblocktrans": NewBlockTransTag(it, WithRestrictedInterpolation()),
+	} {
+		err = pongo2.RegisterTag(name, tag)
+		if err != nil {
+			err = pongo2.ReplaceTag(name, tag)
+		}
+		require.Nil(t, err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: `{% trans "{count, plural, one {# file} other {# files}}" with count=files|length %}`, expected: "3 filer"},
+		{input: `{% trans "{count, plural, one {# file} other {# files}}" with count=1 %}`, expected: "1 fil"},
+		{input: `{% trans "{count, plural, one {# file} other {# files}}" as res with count=0 %}[{{ res }}]`, expected: "[Inga filer]"},
+		{input: `{% blocktrans with gender=user.gender name=user.name %}{gender, select, female {{name} updated her profile} male {{name} updated his profile} other {{name} updated their profile}}{% endblocktrans %}`, expected: "&lt;b&gt; uppdaterade sin profil"},
+		{input: `{% blocktrans with name=user.name %}Hello {{ name }}{% endblocktrans %}`, expected: "Hej &lt;b&gt;"},
+		{input: `{% blocktrans count count=files|length %}%d apple{% plural %}%d apples{% endblocktrans %}`, expected: "3 äpplen"},
+		{input: `{% blocktrans count n=files|length %}%d apple{% plural %}%d apples{% endblocktrans %}`, expected: "3 äpplen"},
+		{input: `{% rblocktrans count n=files|length %}%d pear{% plural %}%d pears{% endblocktrans %}`, expected: "3 päron, {n}"},
+		{input: `{% rtrans "It's '{quoted}', {name}" with name=user.name %}`, expected: "It's {quoted}, &lt;b&gt;"},
+	}
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		result, err := tmpl.Execute(pongo2.Context{
+			"_language": "sv_SE",
+			"files":     []string{"a", "b", "c"},
+			"user":      map[string]string{"name": "<b>", "gender": "female"},
+		})
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+	}
+}
@@ -0,0 +1,68 @@
+package trans
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TranslationError describes a translation that uses template syntax not allowed by restricted interpolation
+type TranslationError struct {
+	ID     string // The msgid of the translation
+	Syntax string // The offending template syntax, e.g. '{% include "x" %}'
+	Reason string
+}
+
+func (e *TranslationError) Error() string {
+	return fmt.Sprintf("translation of %q contains %s: %s", e.ID, e.Reason, e.Syntax)
+}
+
+// ValidateTranslation checks that a translation only uses the variables that are used in the original message,
+// either msgid or msgidPlural, or the variables bound by the tag (e.g. with 'with' or 'count' in blocktrans).
+// Template tags are never allowed, and variables must be written exactly as in the original message,
+// including any filters, so e.g. '{{ name|upper }}' is only allowed if the original message contains '{{ name|upper }}'.
+// The only exception is '{{ "{" }}', which is used to escape braces in the text of a translation.
+//
+// This is used by the 'trans' and 'blocktrans' tags when WithRestrictedInterpolation is set,
+// but can also be used to check catalogs before they are deployed.
+func ValidateTranslation(msgid, msgidPlural, translation string, bound ...string) error {
+	allowed := map[string]bool{}
+	for _, name := range bound {
+		allowed[name] = true
+	}
+	for _, str := range []string{msgid, msgidPlural} {
+		for _, syntax := range templateSyntax.FindAllString(str, -1) {
+			if strings.HasPrefix(syntax, "{{") {
+				allowed[normalizeVariable(syntax)] = true
+			}
+		}
+	}
+
+	for _, syntax := range templateSyntax.FindAllString(translation, -1) {
+		switch {
+		case strings.HasPrefix(syntax, "{#"):
+			// Comments are never rendered
+		case syntax == escapedBrace:
+			// Braces escaped by ICUTranslator and FluentTranslator
+		case strings.HasPrefix(syntax, "{%"):
+			return &TranslationError{ID: msgid, Syntax: syntax, Reason: "a template tag"}
+		case !allowed[normalizeVariable(syntax)]:
+			return &TranslationError{ID: msgid, Syntax: syntax, Reason: "a variable not used in the original message"}
+		}
+	}
+
+	// Unterminated variables and tags are not matched above
+	remaining := templateSyntax.ReplaceAllString(translation, "")
+	for _, delim := range []string{"{{", "{%", "{#"} {
+		if strings.Contains(remaining, delim) {
+			return &TranslationError{ID: msgid, Syntax: delim, Reason: "unbalanced template syntax"}
+		}
+	}
+	return nil
+}
+
+// normalizeVariable removes the delimiters and any insignificant whitespace from a template variable,
+// e.g. '{{  name|upper }}' becomes 'name|upper'
+func normalizeVariable(syntax string) string {
+	syntax = strings.TrimSuffix(strings.TrimPrefix(syntax, "{{"), "}}")
+	return strings.Join(strings.Fields(syntax), " ")
+}
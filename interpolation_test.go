@@ -0,0 +1,108 @@
+package trans
+
+import (
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTranslation(t *testing.T) {
+	type T struct {
+		msgid       string
+		msgidPlural string
+		translation string
+		bound       []string
+		err         bool
+	}
+
+	tests := []T{
+		{msgid: "Hello", translation: "Hej"},
+		{msgid: "Hello {{ name }}", translation: "Hej {{name}}!"},
+		{msgid: "Hello {{ user.name|upper }}", translation: "Hej {{ user.name|upper }}"},
+		{msgid: "{{ n }} file", msgidPlural: "{{ n }} files in {{ dir }}", translation: "{{ n }} filer i {{ dir }}"},
+		{msgid: "Hello", translation: "Hej {{ name }}", bound: []string{"name"}},
+		{msgid: "Hello", translation: "Hej{# comment #}"},
+		{msgid: "Hello", translation: `Hej {{ "{" }}{{ "{" }}`},
+
+		{msgid: "Hello", translation: "Hej {{ name }}", err: true},
+		{msgid: "Hello {{ name }}", translation: "Hej {{ name|safe }}", err: true},
+		{msgid: "Hello {{ user.name }}", translation: "Hej {{ user.password }}", err: true},
+		{msgid: "Hello", translation: "Hej {{ user.name }}", bound: []string{"user"}, err: true},
+		{msgid: "Hello", translation: `Hej {% include "secret.html" %}`, err: true},
+		{msgid: "Hello", translation: `{% ssi "/etc/passwd" %}`, err: true},
+		{msgid: "{% if a %}Hello{% endif %}", translation: "{% if a %}Hej{% endif %}", err: true},
+		{msgid: "Hello", translation: "Hej {{ name", err: true},
+		{msgid: "Hello", translation: "Hej {% ", err: true},
+		{msgid: "Hello", translation: `Hej {{ "}" }}`, err: true},
+	}
+
+	for k, tst := range tests {
+		err := ValidateTranslation(tst.msgid, tst.msgidPlural, tst.translation, tst.bound...)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, translation: %s", k, tst.translation)
+			_, ok := err.(*TranslationError)
+			require.Truef(t, ok, "test: %d, translation: %s", k, tst.translation)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, translation: %s", k, tst.translation)
+	}
+}
+
+func TestTagTransNode_RestrictedInterpolation(t *testing.T) {
+	testTrans := MockTranslator{}
+	testTrans.On("Get", mock.Anything, "Hello").Return("Hej", nil)
+	testTrans.On("Get", mock.Anything, "Tag").Return(`{% if secret %}{{ secret }}{% endif %}`, nil)
+	testTrans.On("Get", mock.Anything, "Hello {{ name }}").Return("Hej {{ name }} {{ secret }}", nil)
+	testTrans.On("Get", mock.Anything, "Hello {{ user }}").Return("Hej {{ user }}", nil)
+	testTrans.On("GetN", mock.Anything, "{{ n }} file", "{{ n }} files", 2).Return("{{ n }} filer {{ secret }}", nil)
+	testTrans.On("GetN", mock.Anything, "{{ n }} file", "{{ n }} files", 1).Return("{{ n }} fil", nil)
+
+	for _, restricted := range []bool{false, true} {
+		var options []TagOption
+		if restricted {
+			options = append(options, WithRestrictedInterpolation())
+		}
+
+		err := pongo2.RegisterTag("trans", NewTransTag(&testTrans, options...))
+		if err != nil {
+			err = pongo2.ReplaceTag("trans", NewTransTag(&testTrans, options...))
+		}
+		require.Nil(t, err)
+
+		err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(&testTrans, options...))
+		if err != nil {
+			err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(&testTrans, options...))
+		}
+		require.Nil(t, err)
+
+		type T struct {
+			input    string
+			expected string
+			err      bool
+		}
+
+		tests := []T{
+			{input: `{% trans "Hello" %}`, expected: "Hej"},
+			{input: `{% blocktrans with user=name %}Hello {{ user }}{% endblocktrans %}`, expected: "Hej Bob"},
+			{input: `{% blocktrans count n=1 %}{{ n }} file{% plural %}{{ n }} files{% endblocktrans %}`, expected: "1 fil"},
+			{input: `{% trans "Tag" %}`, expected: "password", err: restricted},
+			{input: `{% blocktrans %}Hello {{ name }}{% endblocktrans %}`, expected: "Hej Bob password", err: restricted},
+			{input: `{% blocktrans count n=2 %}{{ n }} file{% plural %}{{ n }} files{% endblocktrans %}`, expected: "2 filer password", err: restricted},
+		}
+
+		for k, tst := range tests {
+			tmpl, err := pongo2.FromString(tst.input)
+			require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+			result, err := tmpl.Execute(pongo2.Context{"name": "Bob", "secret": "password"})
+			if tst.err {
+				require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+				continue
+			}
+			require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+			require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package trans
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// rtlScripts contains all scripts that are written from right to left
+var rtlScripts = map[string]bool{
+	"Adlm": true, "Arab": true, "Hebr": true, "Mand": true, "Mend": true,
+	"Nkoo": true, "Rohg": true, "Samr": true, "Syrc": true, "Thaa": true,
+}
+
+// parseLanguage converts a gettext-style language code (e.g. 'sv_SE') to a language tag
+func parseLanguage(code string) (language.Tag, error) {
+	return language.Parse(strings.Replace(code, "_", "-", -1))
+}
+
+// newLanguageInfo returns the information available about a language code
+func newLanguageInfo(code string) LanguageInfo {
+	info := LanguageInfo{
+		Code:           code,
+		Name:           code,
+		NameLocal:      code,
+		NameTranslated: code,
+		Direction:      "ltr",
+	}
+
+	tag, err := parseLanguage(code)
+	if err != nil {
+		return info
+	}
+
+	if name := display.English.Tags().Name(tag); name != "" {
+		info.Name = name
+		info.NameTranslated = name
+	}
+	// The local name has the same granularity as the English name, e.g. 'svenska (Sverige)' for 'Swedish (Sweden)'
+	if name := displayName(tag, tag); name != "" {
+		info.NameLocal = name
+	} else if name := display.Self.Name(tag); name != "" {
+		info.NameLocal = name
+	}
+
+	if script, _ := tag.Script(); rtlScripts[script.String()] {
+		info.Bidi = true
+		info.Direction = "rtl"
+	}
+	return info
+}
+
+// translateLanguageInfo sets the translated name of a language, as seen from the current language
+func translateLanguageInfo(info LanguageInfo, currentLanguage string) LanguageInfo {
+	tag, err := parseLanguage(info.Code)
+	if err != nil {
+		return info
+	}
+
+	current, err := parseLanguage(currentLanguage)
+	if err != nil {
+		return info
+	}
+
+	if name := displayName(current, tag); name != "" {
+		info.NameTranslated = name
+	}
+	return info
+}
+
+// displayName returns the name of tag in the language of in,
+// or an empty string if there are no names in that language
+func displayName(in language.Tag, tag language.Tag) string {
+	namer := display.Tags(in)
+	if namer == nil {
+		return ""
+	}
+	return namer.Name(tag)
+}
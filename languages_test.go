@@ -0,0 +1,38 @@
+package trans
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLanguageInfo(t *testing.T) {
+	require.Equal(t, LanguageInfo{
+		Code:           "sv_SE",
+		Name:           "Swedish (Sweden)",
+		NameLocal:      "svenska (Sverige)",
+		NameTranslated: "Swedish (Sweden)",
+		Direction:      "ltr",
+	}, newLanguageInfo("sv_SE"))
+
+	// The local name has the same granularity as the English name
+	require.Equal(t, "svenska", newLanguageInfo("sv").NameLocal)
+	require.Equal(t, "Österreichisches Deutsch", newLanguageInfo("de_AT").NameLocal)
+
+	info := newLanguageInfo("he_IL")
+	require.True(t, info.Bidi)
+	require.Equal(t, "rtl", info.Direction)
+
+	// Unknown languages should use the language code as name
+	info = newLanguageInfo("not a language")
+	require.Equal(t, "not a language", info.Name)
+	require.Equal(t, "not a language", info.NameLocal)
+	require.Equal(t, "ltr", info.Direction)
+
+	require.Equal(t, "svenska (Sverige)", translateLanguageInfo(newLanguageInfo("sv_SE"), "sv").NameTranslated)
+	require.Equal(t, "Swedish (Sweden)", translateLanguageInfo(newLanguageInfo("sv_SE"), "").NameTranslated)
+
+	// Languages without names of their own, such as the pseudo-language, keep the English name
+	require.Equal(t, "Swedish (Sweden)", translateLanguageInfo(newLanguageInfo("sv_SE"), "qps").NameTranslated)
+	require.Equal(t, "qps", newLanguageInfo("qps").NameLocal)
+}
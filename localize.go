@@ -0,0 +1,279 @@
+package trans
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// currencyPatterns contains the standard CLDR currency patterns of the languages whose pattern differs from the
+// root pattern '¤#,##0.00'. Only the placement of the symbol ('¤'), the sign ('-') and spaces is used from the
+// pattern; the number itself is formatted with the separators and currency digits of the language.
+// x/text doesn't expose the currency patterns, so this is a copy of a subset of CLDR: languages that aren't listed
+// use the root pattern. Regions are only listed if they differ from the language.
+var currencyPatterns = map[string]string{
+	"be": "#,##0.00 ¤", "bg": "#,##0.00 ¤", "bs": "#,##0.00 ¤", "ca": "#,##0.00 ¤", "cs": "#,##0.00 ¤",
+	"da": "#,##0.00 ¤", "de": "#,##0.00 ¤", "el": "#,##0.00 ¤", "es": "#,##0.00 ¤", "et": "#,##0.00 ¤",
+	"eu": "#,##0.00 ¤", "fi": "#,##0.00 ¤", "fr": "#,##0.00 ¤", "gl": "#,##0.00 ¤", "hr": "#,##0.00 ¤",
+	"hu": "#,##0.00 ¤", "is": "#,##0.00 ¤", "it": "#,##0.00 ¤", "lt": "#,##0.00 ¤", "lv": "#,##0.00 ¤",
+	"mk": "#,##0.00 ¤", "nb": "#,##0.00 ¤", "nn": "#,##0.00 ¤", "no": "#,##0.00 ¤", "pl": "#,##0.00 ¤",
+	"ro": "#,##0.00 ¤", "ru": "#,##0.00 ¤", "sk": "#,##0.00 ¤", "sl": "#,##0.00 ¤", "sq": "#,##0.00 ¤",
+	"sr": "#,##0.00 ¤", "sv": "#,##0.00 ¤", "uk": "#,##0.00 ¤", "vi": "#,##0.00 ¤",
+
+	"nl": "¤ #,##0.00;¤ -#,##0.00", "pt": "¤ #,##0.00", "pt-PT": "#,##0.00 ¤",
+	"de-AT": "¤ #,##0.00", "de-CH": "¤ #,##0.00;¤-#,##0.00", "de-LI": "¤ #,##0.00", "it-CH": "¤ #,##0.00;¤-#,##0.00",
+	"es-419": "¤#,##0.00", "es-MX": "¤#,##0.00", "es-US": "¤#,##0.00",
+}
+
+// currencyPattern returns the CLDR currency pattern of a language
+func currencyPattern(tag language.Tag) string {
+	base, _ := tag.Base()
+	if region, confidence := tag.Region(); confidence == language.Exact {
+		if pattern, ok := currencyPatterns[base.String()+"-"+region.String()]; ok {
+			return pattern
+		}
+	}
+	if pattern, ok := currencyPatterns[base.String()]; ok {
+		return pattern
+	}
+	return "¤#,##0.00"
+}
+
+// applyCurrencyPattern formats an amount with a CLDR currency pattern, where amount is the formatted absolute value.
+// Patterns without a negative subpattern use the positive one prefixed with the minus sign.
+// Spaces are written as no-break spaces, as in CLDR.
+func applyCurrencyPattern(pattern string, negative bool, amount, symbol, minus string) string {
+	subpatterns := strings.SplitN(pattern, ";", 2)
+	pattern = subpatterns[0]
+	if negative {
+		if len(subpatterns) == 2 {
+			pattern = subpatterns[1]
+		} else {
+			pattern = "-" + pattern
+		}
+	}
+
+	var sb strings.Builder
+	inNumber := false
+	for _, c := range pattern {
+		if strings.ContainsRune("#0,.", c) {
+			if !inNumber {
+				sb.WriteString(amount)
+				inNumber = true
+			}
+			continue
+		}
+		inNumber = false
+
+		switch c {
+		case '¤':
+			sb.WriteString(symbol)
+		case '-':
+			sb.WriteString(minus)
+		case ' ':
+			sb.WriteString("\u00a0")
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// LocalizeFunctions returns the functions 'localize_number', 'localize_currency' and 'localize_percent', which format
+// numbers according to the language of the template, i.e. '_language' as set by the 'language'-tag or the template context.
+//
+// Usage:
+//
+//	pongo2.Globals.Update(trans.LocalizeFunctions())
+//
+//	// and then, in your templates
+//	{{ localize_number(1234.5) }}            // 1,234.5 in English, 1 234,5 in Swedish
+//	{{ localize_currency(price, "EUR") }}    // €1,234.50 in English, 1 234,50 € in Swedish
+//	{{ localize_percent(0.25) }}             // 25% in English, 25 % in Swedish
+//
+// Note that these are functions, not filters: pongo2 filters are only given the value and a parameter, and not the
+// execution context, so a filter such as '{{ price|localize_currency:"EUR" }}' cannot read the current language.
+// See LocalizeFilters for filters with the same names, which use a fixed language instead.
+// Values that aren't numbers are returned unchanged.
+func LocalizeFunctions() pongo2.Context {
+	return pongo2.Context{
+		"localize_number": func(ctx *pongo2.ExecutionContext, value *pongo2.Value) *pongo2.Value {
+			n, ok := localizeNumber(value.Interface())
+			if !ok {
+				return value
+			}
+			return pongo2.AsValue(formatNumber(getTransCtx(ctx).Language, n))
+		},
+		"localize_currency": func(ctx *pongo2.ExecutionContext, value *pongo2.Value, code string) (*pongo2.Value, error) {
+			n, ok := localizeNumber(value.Interface())
+			if !ok {
+				return value, nil
+			}
+
+			str, err := formatCurrency(getTransCtx(ctx).Language, n, code)
+			if err != nil {
+				return nil, err
+			}
+			return pongo2.AsValue(str), nil
+		},
+		"localize_percent": func(ctx *pongo2.ExecutionContext, value *pongo2.Value) *pongo2.Value {
+			n, ok := localizeNumber(value.Interface())
+			if !ok {
+				return value
+			}
+			return pongo2.AsValue(formatPercent(getTransCtx(ctx).Language, n))
+		},
+	}
+}
+
+// LocalizeFilters returns the filters 'localize_number', 'localize_currency' and 'localize_percent'.
+// Since filters cannot read the language of the template, the numbers are formatted in defaultLanguage,
+// unless 'localize_number' and 'localize_percent' are given a language as parameter, e.g. '_language'.
+// Use LocalizeFunctions to always format the numbers in the language of the template.
+//
+// Usage:
+//
+//	for name, filter := range trans.LocalizeFilters("en") {
+//		pongo2.RegisterFilter(name, filter)
+//	}
+//
+//	// and then, in your templates
+//	{{ 1234.5|localize_number }}               // 1,234.5
+//	{{ 1234.5|localize_number:_language }}     // 1 234,5 if _language is 'sv_SE'
+//	{{ price|localize_currency:"EUR" }}        // €1,234.50
+//	{{ 0.25|localize_percent }}                // 25%
+//
+// Values that aren't numbers are returned unchanged.
+func LocalizeFilters(defaultLanguage string) map[string]pongo2.FilterFunction {
+	// filterLanguage returns the language given as parameter, or defaultLanguage
+	filterLanguage := func(param *pongo2.Value) string {
+		if lang := param.String(); !param.IsNil() && lang != "" {
+			return lang
+		}
+		return defaultLanguage
+	}
+
+	return map[string]pongo2.FilterFunction{
+		"localize_number": func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+			n, ok := localizeNumber(in.Interface())
+			if !ok {
+				return in, nil
+			}
+			return pongo2.AsValue(formatNumber(filterLanguage(param), n)), nil
+		},
+		"localize_currency": func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+			n, ok := localizeNumber(in.Interface())
+			if !ok {
+				return in, nil
+			}
+
+			str, err := formatCurrency(defaultLanguage, n, param.String())
+			if err != nil {
+				return nil, &pongo2.Error{
+					Sender:    "filter:localize_currency",
+					OrigError: err,
+				}
+			}
+			return pongo2.AsValue(str), nil
+		},
+		"localize_percent": func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+			n, ok := localizeNumber(in.Interface())
+			if !ok {
+				return in, nil
+			}
+			return pongo2.AsValue(formatPercent(filterLanguage(param), n)), nil
+		},
+	}
+}
+
+// localizeNumber converts a value to a number that can be formatted, or returns false if it isn't a number.
+// Integers are kept as integers, since large values would lose precision as float64
+func localizeNumber(value interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.String:
+		if n, err := strconv.ParseInt(strings.TrimSpace(v.String()), 10, 64); err == nil {
+			return n, true
+		}
+	}
+	return messageNumber(value)
+}
+
+// localeTag returns the language tag for a language code, e.g. 'sv_SE'.
+// Invalid or missing codes are formatted in the root locale
+func localeTag(code string) language.Tag {
+	tag, err := parseLanguage(code)
+	if err != nil {
+		return language.Und
+	}
+	return tag
+}
+
+// FormatNumber formats a number with the grouping and decimal separators of a language, e.g. '1 234,5' for 'sv_SE'
+func FormatNumber(lang string, n float64) string {
+	return formatNumber(lang, n)
+}
+
+// formatNumber formats a number, which can be any type supported by number.Decimal
+func formatNumber(lang string, n interface{}) string {
+	return message.NewPrinter(localeTag(lang)).Sprint(number.Decimal(n))
+}
+
+// FormatPercent formats a fraction as a percentage in a language, e.g. '25 %' for 0.25 in 'sv_SE'
+func FormatPercent(lang string, n float64) string {
+	return formatPercent(lang, n)
+}
+
+// formatPercent formats a fraction as a percentage, where n can be any type supported by number.Percent
+func formatPercent(lang string, n interface{}) string {
+	return message.NewPrinter(localeTag(lang)).Sprint(number.Percent(n))
+}
+
+// FormatCurrency formats an amount in a currency, given as an ISO 4217 code (e.g. 'EUR'), in a language.
+// The amount is rounded to the standard number of decimals of the currency, and the symbol of the currency
+// in the language and the sign are placed according to the CLDR currency pattern of the language,
+// e.g. '1 234,50 €' for 'sv_SE' and '€ -1.234,50' for 'nl'.
+// Only the patterns of the most common languages are included, other languages place the symbol before the amount.
+func FormatCurrency(lang string, n float64, code string) (string, error) {
+	return formatCurrency(lang, n, code)
+}
+
+// formatCurrency formats an amount in a currency, where n is a float64, int64 or uint64 as returned by localizeNumber
+func formatCurrency(lang string, n interface{}, code string) (string, error) {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return "", fmt.Errorf("invalid currency '%s': %v", code, err)
+	}
+
+	tag := localeTag(lang)
+	p := message.NewPrinter(tag)
+	scale, _ := currency.Standard.Rounding(unit)
+	symbol := p.Sprint(currency.Symbol(unit))
+	minus := strings.TrimSuffix(p.Sprint(number.Decimal(-1)), p.Sprint(number.Decimal(1)))
+
+	negative := false
+	switch v := n.(type) {
+	case float64:
+		if negative = v < 0; negative {
+			n = -v
+		}
+	case int64:
+		// The absolute value is converted to uint64, so that the smallest int64 doesn't overflow
+		if negative = v < 0; negative {
+			n = uint64(-v)
+		}
+	}
+	amount := p.Sprint(number.Decimal(n, number.Scale(scale)))
+	return applyCurrencyPattern(currencyPattern(tag), negative, amount, symbol, minus), nil
+}
@@ -0,0 +1,148 @@
+package trans
+
+import (
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatCurrency(t *testing.T) {
+	type T struct {
+		language string
+		amount   float64
+		currency string
+		expected string
+		err      bool
+	}
+
+	tests := []T{
+		{language: "en", amount: 1234.5, currency: "EUR", expected: "€1,234.50"},
+		{language: "en_US", amount: -1234.5, currency: "USD", expected: "-$1,234.50"},
+		{language: "en", amount: 1234.6, currency: "JPY", expected: "¥1,235"},
+		{language: "sv_SE", amount: 1234.5, currency: "SEK", expected: "1\u00a0234,50\u00a0kr"},
+		{language: "sv_SE", amount: -1234.5, currency: "EUR", expected: "−1\u00a0234,50\u00a0€"},
+		{language: "de", amount: 1234567.891, currency: "EUR", expected: "1.234.567,89\u00a0€"},
+		{language: "de_CH", amount: 1234.5, currency: "CHF", expected: "CHF\u00a01’234.50"},
+		{language: "nl", amount: 1234.5, currency: "EUR", expected: "€\u00a01.234,50"},
+		{language: "nl", amount: -5, currency: "EUR", expected: "€\u00a0-5,00"},
+		{language: "de_CH", amount: -5, currency: "CHF", expected: "CHF-5.00"},
+		{language: "de_AT", amount: -5, currency: "EUR", expected: "-€\u00a05,00"},
+		{language: "sq", amount: 1, currency: "EUR", expected: "1,00\u00a0€"},
+		{language: "pt_BR", amount: 1234.5, currency: "BRL", expected: "R$\u00a01.234,50"},
+		{language: "pt_PT", amount: 12345.5, currency: "EUR", expected: "12\u00a0345,50\u00a0€"},
+		{language: "es_MX", amount: 1234.5, currency: "MXN", expected: "$1,234.50"},
+		{language: "ja", amount: -1234, currency: "JPY", expected: "-￥1,234"},
+		{language: "", amount: 1234.5, currency: "EUR", expected: "€1,234.50"},
+		{language: "invalid language", amount: 1234.5, currency: "EUR", expected: "€1,234.50"},
+		{language: "en", amount: 1, currency: "XYZ", err: true},
+		{language: "en", amount: 1, currency: "", err: true},
+	}
+
+	for k, tst := range tests {
+		result, err := FormatCurrency(tst.language, tst.amount, tst.currency)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d", k)
+			continue
+		}
+		require.Nilf(t, err, "test: %d", k)
+		require.Equalf(t, tst.expected, result, "test: %d", k)
+	}
+}
+
+func TestLocalizeFunctions(t *testing.T) {
+	type T struct {
+		input    string
+		language string
+		expected string
+		err      bool
+	}
+
+	tests := []T{
+		{input: `{{ localize_number(1234567.5) }}`, language: "en", expected: "1,234,567.5"},
+		{input: `{{ localize_number(1234567.5) }}`, language: "sv_SE", expected: "1\u00a0234\u00a0567,5"},
+		{input: `{{ localize_number(1234567) }}`, language: "de", expected: "1.234.567"},
+		{input: `{{ localize_number("1234.5") }}`, language: "fr", expected: "1\u00a0234,5"},
+		{input: `{{ localize_number(1234.5) }}`, language: "", expected: "1,234.5"},
+		{input: `{{ localize_number(big) }}`, language: "en", expected: "9,007,199,254,740,993"},
+		{input: `{{ localize_number(9007199254740993) }}`, language: "en", expected: "9,007,199,254,740,993"},
+		{input: `{{ localize_number("9007199254740993") }}`, language: "en", expected: "9,007,199,254,740,993"},
+		{input: `{{ localize_number("n/a") }}`, language: "en", expected: "n/a"},
+		{input: `{{ localize_percent(0.256) }}`, language: "en", expected: "26%"},
+		{input: `{{ localize_percent(0.25) }}`, language: "sv_SE", expected: "25\u00a0%"},
+		{input: `{{ localize_currency(price, "EUR") }}`, language: "en", expected: "€1,234.50"},
+		{input: `{{ localize_currency(price, "EUR") }}`, language: "fr_FR", expected: "1\u00a0234,50\u00a0€"},
+		{input: `{{ localize_currency(price, "EUR") }}{% language "sv_SE" %} {{ localize_currency(price, "SEK") }}{% endlanguage %}`,
+			language: "en", expected: "€1,234.50 1\u00a0234,50\u00a0kr"},
+		{input: `{{ localize_currency(price, currency) }}`, language: "en", expected: "$1,234.50"},
+		{input: `{{ localize_currency("free", "EUR") }}`, language: "en", expected: "free"},
+		{input: `{{ localize_currency(big, "JPY") }}`, language: "en", expected: "¥9,007,199,254,740,993"},
+		{input: `{{ localize_currency(-big, "JPY") }}`, language: "en", expected: "-¥9,007,199,254,740,993"},
+		{input: `{{ localize_currency(price, "XYZ") }}`, language: "en", err: true},
+	}
+
+	err := pongo2.RegisterTag("language", NewLanguageTag())
+	if err != nil {
+		err = pongo2.ReplaceTag("language", NewLanguageTag())
+	}
+	require.Nil(t, err)
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		ctx := LocalizeFunctions()
+		ctx.Update(pongo2.Context{"_language": tst.language, "price": 1234.5, "currency": "USD", "big": int64(9007199254740993)})
+		result, err := tmpl.Execute(ctx)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+	}
+}
+
+func TestLocalizeFilters(t *testing.T) {
+	type T struct {
+		input    string
+		expected string
+		err      bool
+	}
+
+	tests := []T{
+		{input: `{{ 1234567.5|localize_number }}`, expected: "1\u00a0234\u00a0567,5"},
+		{input: `{{ 1234567.5|localize_number:"en" }}`, expected: "1,234,567.5"},
+		{input: `{{ 1234567.5|localize_number:_language }}`, expected: "1.234.567,5"},
+		{input: `{{ big|localize_number:"en" }}`, expected: "9,007,199,254,740,993"},
+		{input: `{{ "n/a"|localize_number }}`, expected: "n/a"},
+		{input: `{{ 0.25|localize_percent }}`, expected: "25\u00a0%"},
+		{input: `{{ 0.256|localize_percent:"en" }}`, expected: "26%"},
+		{input: `{{ price|localize_currency:"EUR" }}`, expected: "1\u00a0234,50\u00a0€"},
+		{input: `{{ price|localize_currency:currency }}`, expected: "1\u00a0234,50\u00a0US$"},
+		{input: `{{ "free"|localize_currency:"EUR" }}`, expected: "free"},
+		{input: `{{ price|localize_currency:"XYZ" }}`, err: true},
+		{input: `{{ price|localize_currency }}`, err: true},
+	}
+
+	for name, filter := range LocalizeFilters("sv_SE") {
+		err := pongo2.RegisterFilter(name, filter)
+		if err != nil {
+			err = pongo2.ReplaceFilter(name, filter)
+		}
+		require.Nil(t, err)
+	}
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		result, err := tmpl.Execute(pongo2.Context{"_language": "de", "price": 1234.5, "currency": "USD", "big": int64(9007199254740993)})
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+	}
+}
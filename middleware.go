@@ -0,0 +1,319 @@
+package trans
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+	"golang.org/x/text/language"
+)
+
+type contextKey int
+
+const transCtxKey contextKey = iota
+
+// MiddlewareOption configures how the language is negotiated by LanguageMiddleware
+type MiddlewareOption func(m *languageMiddleware)
+
+// WithQueryParameter sets the name of the query parameter used to select the language. Defaults to 'lang'.
+// An empty name disables language selection via query parameters.
+func WithQueryParameter(name string) MiddlewareOption {
+	return func(m *languageMiddleware) {
+		m.queryParameter = name
+	}
+}
+
+// WithCookie sets the name of the cookie used to select the language. Defaults to 'lang'.
+// An empty name disables language selection via cookies.
+func WithCookie(name string) MiddlewareOption {
+	return func(m *languageMiddleware) {
+		m.cookie = name
+	}
+}
+
+// WithURLPrefix enables language selection via the first segment of the URL path, e.g. '/sv_SE/about'.
+// If strip is set, the prefix is removed from the request path before it's passed on to the next handler,
+// so that '/sv_SE/about' and '/about' are handled by the same route.
+func WithURLPrefix(strip bool) MiddlewareOption {
+	return func(m *languageMiddleware) {
+		m.urlPrefix = true
+		m.stripPrefix = strip
+	}
+}
+
+// WithDefaultLanguage sets the language to use when no other language can be negotiated.
+// Defaults to the first available language.
+func WithDefaultLanguage(code string) MiddlewareOption {
+	return func(m *languageMiddleware) {
+		m.defaultLanguage = code
+	}
+}
+
+// WithDomain sets the translation domain stored on the request context
+func WithDomain(domain string) MiddlewareOption {
+	return func(m *languageMiddleware) {
+		m.domain = domain
+	}
+}
+
+type languageMiddleware struct {
+	provider LanguageProvider
+
+	queryParameter  string
+	cookie          string
+	urlPrefix       bool
+	stripPrefix     bool
+	defaultLanguage string
+	domain          string
+
+	languages *languageMatcher
+}
+
+// languageMatcher matches the requested languages against the languages of a provider.
+// The matcher is rebuilt when the translations are reloaded
+type languageMatcher struct {
+	provider        LanguageProvider
+	defaultLanguage string
+
+	// mu protects the fields below
+	mu      sync.Mutex
+	codes   []string
+	matcher language.Matcher
+}
+
+type languageMatcherID struct {
+	provider        LanguageProvider
+	defaultLanguage string
+}
+
+// languageMatchers contains the matchers of providers that can be reloaded. Since the middlewares share their matcher,
+// only one function is registered with OnReload per provider and default language, no matter how many times
+// LanguageMiddleware is called.
+var (
+	languageMatchersMu sync.Mutex
+	languageMatchers   = map[languageMatcherID]*languageMatcher{}
+)
+
+// newLanguageMatcher returns the matcher for the languages of provider.
+// If provider implements ReloadNotifier, the matcher is shared with the other middlewares using the same provider
+// and default language, and rebuilt every time the translations are reloaded.
+func newLanguageMatcher(provider LanguageProvider, defaultLanguage string) *languageMatcher {
+	// Providers that aren't pointers may not be usable as map keys, and are never reloaded
+	notifier, ok := provider.(ReloadNotifier)
+	if !ok || reflect.ValueOf(provider).Kind() != reflect.Ptr {
+		return &languageMatcher{provider: provider, defaultLanguage: defaultLanguage}
+	}
+
+	languageMatchersMu.Lock()
+	defer languageMatchersMu.Unlock()
+
+	id := languageMatcherID{provider: provider, defaultLanguage: defaultLanguage}
+	if m, ok := languageMatchers[id]; ok {
+		return m
+	}
+
+	m := &languageMatcher{provider: provider, defaultLanguage: defaultLanguage}
+	languageMatchers[id] = m
+	notifier.OnReload(m.invalidate)
+	return m
+}
+
+// LanguageMiddleware creates a net/http middleware that negotiates the language of each request,
+// and stores it on the request context. The language is selected from the languages available in provider,
+// by checking the following, in order:
+//
+//   - The first segment of the URL path, if enabled with WithURLPrefix
+//   - The query parameter 'lang'
+//   - The cookie 'lang'
+//   - The 'Accept-Language'-header
+//
+// Usage:
+//
+//	handler := trans.LanguageMiddleware(translator, trans.WithDefaultLanguage("en_GB"))(mux)
+//
+//	// and then, in your handlers
+//	tmpl.ExecuteWriter(trans.TemplateContext(req.Context()).Update(pongo2.Context{...}), w)
+//
+// The available languages are read once, and again every time the translations are reloaded
+// if provider is a ReloadNotifier, e.g. a TemplateTranslator. Middlewares using the same provider share
+// the available languages, so LanguageMiddleware can be called any number of times without registering
+// another function with OnReload.
+func LanguageMiddleware(provider LanguageProvider, options ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &languageMiddleware{
+		provider:       provider,
+		queryParameter: "lang",
+		cookie:         "lang",
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	m.languages = newLanguageMatcher(provider, m.defaultLanguage)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang, r := m.negotiate(w, r)
+			if lang != "" {
+				w.Header().Set("Content-Language", strings.Replace(lang, "_", "-", -1))
+			}
+
+			ctx := WithTransCtx(r.Context(), TransCtx{Language: lang, Domain: m.domain})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// negotiate returns the language to use for a request, and the request to pass on to the next handler.
+// If the language is selected by the URL prefix, and the prefix should be stripped, a copy of the request
+// with the updated path is returned, so that the request of the caller is left unchanged
+func (m *languageMiddleware) negotiate(w http.ResponseWriter, r *http.Request) (string, *http.Request) {
+	codes, matcher := m.languages.get()
+	if len(codes) == 0 {
+		return m.defaultLanguage, r
+	}
+
+	if m.urlPrefix {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		prefix := path
+		if idx := strings.Index(path, "/"); idx >= 0 {
+			prefix = path[:idx]
+		}
+
+		if lang, ok := matchExplicit(codes, matcher, prefix); ok {
+			if m.stripPrefix {
+				r = r.Clone(r.Context())
+				r.URL.Path = "/" + strings.TrimPrefix(path[len(prefix):], "/")
+				r.URL.RawPath = ""
+			}
+			return lang, r
+		}
+	}
+
+	if m.queryParameter != "" {
+		if lang, ok := matchExplicit(codes, matcher, r.URL.Query().Get(m.queryParameter)); ok {
+			return lang, r
+		}
+	}
+
+	if m.cookie != "" {
+		w.Header().Add("Vary", "Cookie")
+		if cookie, err := r.Cookie(m.cookie); err == nil {
+			if lang, ok := matchExplicit(codes, matcher, cookie.Value); ok {
+				return lang, r
+			}
+		}
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	if tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil && len(tags) > 0 {
+		if _, idx, confidence := matcher.Match(tags...); confidence != language.No {
+			return codes[idx], r
+		}
+	}
+
+	if m.defaultLanguage != "" {
+		return m.defaultLanguage, r
+	}
+	return codes[0], r
+}
+
+// matchExplicit matches a language selected by the user (e.g. via a query parameter) against the available languages.
+// Only close matches are accepted, e.g. 'sv' or 'sv-SE' for 'sv_SE'.
+func matchExplicit(codes []string, matcher language.Matcher, value string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+
+	for _, code := range codes {
+		if strings.EqualFold(strings.Replace(value, "-", "_", -1), code) {
+			return code, true
+		}
+	}
+
+	tag, err := parseLanguage(value)
+	if err != nil {
+		return "", false
+	}
+
+	if _, idx, confidence := matcher.Match(tag); confidence >= language.High {
+		return codes[idx], true
+	}
+	return "", false
+}
+
+// get returns the available languages, and a matcher for them.
+// The matcher is built the first time it's needed, and again after it has been invalidated.
+func (m *languageMatcher) get() ([]string, language.Matcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.matcher != nil {
+		return m.codes, m.matcher
+	}
+
+	var codes []string
+	for _, info := range m.provider.Languages() {
+		codes = append(codes, info.Code)
+	}
+
+	// The default language is preferred when the match is ambiguous
+	for k, code := range codes {
+		if code == m.defaultLanguage {
+			copy(codes[1:k+1], codes[:k])
+			codes[0] = code
+			break
+		}
+	}
+
+	tags := make([]language.Tag, 0, len(codes))
+	for _, code := range codes {
+		tag, err := parseLanguage(code)
+		if err != nil {
+			tag = language.Und
+		}
+		tags = append(tags, tag)
+	}
+
+	m.codes = codes
+	m.matcher = language.NewMatcher(tags)
+	return m.codes, m.matcher
+}
+
+// invalidate makes the matcher be rebuilt on the next request, since the available languages may have changed
+func (m *languageMatcher) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matcher = nil
+}
+
+// WithTransCtx returns a copy of ctx, with the translation context set
+func WithTransCtx(ctx context.Context, transCtx TransCtx) context.Context {
+	return context.WithValue(ctx, transCtxKey, transCtx)
+}
+
+// TransCtxFromContext returns the translation context stored by LanguageMiddleware or WithTransCtx
+func TransCtxFromContext(ctx context.Context) (TransCtx, bool) {
+	transCtx, ok := ctx.Value(transCtxKey).(TransCtx)
+	return transCtx, ok
+}
+
+// LanguageFromContext returns the language stored by LanguageMiddleware or WithTransCtx,
+// or an empty string if no language has been set
+func LanguageFromContext(ctx context.Context) string {
+	transCtx, _ := TransCtxFromContext(ctx)
+	return transCtx.Language
+}
+
+// TemplateContext returns a pongo2.Context containing the language and domain stored on ctx,
+// which can be used when executing templates
+func TemplateContext(ctx context.Context) pongo2.Context {
+	transCtx, _ := TransCtxFromContext(ctx)
+	return pongo2.Context{
+		"_language": transCtx.Language,
+		"_domain":   transCtx.Domain,
+	}
+}
@@ -0,0 +1,186 @@
+package trans
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+)
+
+type testLanguageProvider []string
+
+func (p testLanguageProvider) Languages() []LanguageInfo {
+	var languages []LanguageInfo
+	for _, code := range p {
+		languages = append(languages, newLanguageInfo(code))
+	}
+	return languages
+}
+
+func (p testLanguageProvider) LanguageInfo(code string) LanguageInfo {
+	return newLanguageInfo(code)
+}
+
+func TestLanguageMiddleware(t *testing.T) {
+	provider := testLanguageProvider{"de_DE", "en_GB", "pt_BR", "pt_PT", "sv_SE"}
+
+	type T struct {
+		path           string
+		cookie         string
+		acceptLanguage string
+		expected       string
+		expectedPath   string
+	}
+
+	tests := []T{
+		{path: "/", expected: "en_GB", expectedPath: "/"},
+		{path: "/?lang=sv_SE", expected: "sv_SE", expectedPath: "/"},
+		{path: "/?lang=sv-se", expected: "sv_SE", expectedPath: "/"},
+		{path: "/?lang=sv", expected: "sv_SE", expectedPath: "/"},
+		{path: "/?lang=fi", expected: "en_GB", expectedPath: "/"},
+		{path: "/?lang=invalid-language", expected: "en_GB", expectedPath: "/"},
+		{path: "/", cookie: "pt_PT", expected: "pt_PT", expectedPath: "/"},
+		{path: "/?lang=de", cookie: "pt_PT", expected: "de_DE", expectedPath: "/"},
+		{path: "/", acceptLanguage: "sv-SE,sv;q=0.9,en;q=0.8", expected: "sv_SE", expectedPath: "/"},
+		{path: "/", acceptLanguage: "fi;q=0.9,de;q=0.5,sv;q=0.8", expected: "sv_SE", expectedPath: "/"},
+		{path: "/", acceptLanguage: "pt-PT", expected: "pt_PT", expectedPath: "/"},
+		{path: "/", acceptLanguage: "de-AT", expected: "de_DE", expectedPath: "/"},
+		{path: "/", acceptLanguage: "fi", expected: "en_GB", expectedPath: "/"},
+		{path: "/", acceptLanguage: "not a language;;", expected: "en_GB", expectedPath: "/"},
+		{path: "/", cookie: "de_DE", acceptLanguage: "sv", expected: "de_DE", expectedPath: "/"},
+		{path: "/sv_SE/about", expected: "sv_SE", expectedPath: "/about"},
+		{path: "/pt-br", expected: "pt_BR", expectedPath: "/"},
+		{path: "/about?lang=de", acceptLanguage: "sv", expected: "de_DE", expectedPath: "/about"},
+		{path: "/de_DE/about?lang=sv", expected: "de_DE", expectedPath: "/about"},
+	}
+
+	for k, tst := range tests {
+		var transCtx TransCtx
+		var path string
+		handler := LanguageMiddleware(provider,
+			WithURLPrefix(true),
+			WithDefaultLanguage("en_GB"),
+			WithDomain("domain"),
+		)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			transCtx, _ = TransCtxFromContext(r.Context())
+			path = r.URL.Path
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, tst.path, nil)
+		if tst.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "lang", Value: tst.cookie})
+		}
+		if tst.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tst.acceptLanguage)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equalf(t, TransCtx{Language: tst.expected, Domain: "domain"}, transCtx, "test: %d, path: %s", k, tst.path)
+		require.Equalf(t, tst.expectedPath, path, "test: %d, path: %s", k, tst.path)
+
+		// The request of the caller is not modified when the prefix is stripped
+		require.Equalf(t, strings.SplitN(tst.path, "?", 2)[0], req.URL.Path, "test: %d, path: %s", k, tst.path)
+	}
+}
+
+// reloadingLanguageProvider counts the calls to Languages, and lets the test trigger a reload
+type reloadingLanguageProvider struct {
+	testLanguageProvider
+	calls    int
+	onReload []func()
+}
+
+func (p *reloadingLanguageProvider) Languages() []LanguageInfo {
+	p.calls++
+	return p.testLanguageProvider.Languages()
+}
+
+func (p *reloadingLanguageProvider) OnReload(fn func()) {
+	p.onReload = append(p.onReload, fn)
+}
+
+func TestLanguageMiddleware_Reload(t *testing.T) {
+	provider := &reloadingLanguageProvider{testLanguageProvider: testLanguageProvider{"en_GB"}}
+
+	var lang string
+	handler := LanguageMiddleware(provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang = LanguageFromContext(r.Context())
+	}))
+
+	// The available languages are only read once
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?lang=sv", nil))
+		require.Equal(t, "en_GB", lang)
+	}
+	require.Equal(t, 1, provider.calls)
+
+	// ... until the translations are reloaded
+	provider.testLanguageProvider = testLanguageProvider{"en_GB", "sv_SE"}
+	for _, fn := range provider.onReload {
+		fn()
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?lang=sv", nil))
+	require.Equal(t, "sv_SE", lang)
+	require.Equal(t, 2, provider.calls)
+
+	// Creating the middleware again doesn't register another function with OnReload,
+	// and the languages are shared with the first middleware
+	for i := 0; i < 3; i++ {
+		LanguageMiddleware(provider)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	require.Len(t, provider.onReload, 1)
+	require.Equal(t, 2, provider.calls)
+
+	// ... unless the default language differs, since it's preferred by the matcher
+	LanguageMiddleware(provider, WithDefaultLanguage("sv_SE"))
+	require.Len(t, provider.onReload, 2)
+}
+
+func TestLanguageMiddleware_Options(t *testing.T) {
+	provider := testLanguageProvider{"en_GB", "sv_SE"}
+
+	var ctx pongo2.Context
+	var path string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = TemplateContext(r.Context())
+		path = r.URL.Path
+	})
+
+	// Without any options, the first language is the default, and URL prefixes are not used
+	rec := httptest.NewRecorder()
+	LanguageMiddleware(provider)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sv_SE/about", nil))
+	require.Equal(t, pongo2.Context{"_language": "en_GB", "_domain": ""}, ctx)
+	require.Equal(t, "/sv_SE/about", path)
+	require.Equal(t, "en-GB", rec.Header().Get("Content-Language"))
+
+	// URL prefixes can be used without being stripped
+	LanguageMiddleware(provider, WithURLPrefix(false))(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sv_SE/about", nil))
+	require.Equal(t, "sv_SE", ctx["_language"])
+	require.Equal(t, "/sv_SE/about", path)
+
+	// Query parameter and cookie names can be changed
+	handler := LanguageMiddleware(provider, WithQueryParameter("l"), WithCookie("language"))(next)
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?l=sv", nil))
+	require.Equal(t, "sv_SE", ctx["_language"])
+
+	req := httptest.NewRequest(http.MethodGet, "/?lang=sv", nil)
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "sv"})
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, "en_GB", ctx["_language"])
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "language", Value: "sv"})
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, "sv_SE", ctx["_language"])
+
+	// Without any languages, the default language is used
+	LanguageMiddleware(testLanguageProvider{}, WithDefaultLanguage("de"))(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "de", ctx["_language"])
+
+	// A missing translation context is returned as an empty language
+	require.Equal(t, "", LanguageFromContext(req.Context()))
+}
@@ -0,0 +1,172 @@
+package trans
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+// MissingTranslation describes a message that could not be translated
+type MissingTranslation struct {
+	Language string
+	Domain   string
+	Context  string
+	ID       string
+	IDPlural string
+}
+
+// MissingTranslationHandler is called by TemplateTranslator when a message could not be translated
+// to the requested language, or any of its fallback languages
+type MissingTranslationHandler func(m MissingTranslation)
+
+// WithMissingTranslationHandler sets a function that is called every time a message could not be translated.
+// The handler is called from the goroutine executing the template, and must be safe for concurrent use.
+func WithMissingTranslationHandler(handler MissingTranslationHandler) TranslatorOption {
+	return func(t *TemplateTranslator) {
+		t.missingHandler = handler
+	}
+}
+
+type missingKey struct {
+	domain  string
+	context string
+	id      string
+}
+
+// MissingTranslationCollector collects missing translations in memory, so that they can be written to a .pot-file.
+// Each message is only stored once, together with the languages it was missing in.
+//
+// Usage:
+//
+//	collector := trans.NewMissingTranslationCollector()
+//	t, err := trans.NewTemplateTranslator(localeFS, "locales", trans.WithMissingTranslationHandler(collector.Handle))
+//
+//	// and then, after browsing the site
+//	collector.WritePot(w, "default")
+type MissingTranslationCollector struct {
+	mu        sync.Mutex
+	messages  map[missingKey]*MissingTranslation
+	languages map[missingKey]map[string]bool
+	order     []missingKey
+}
+
+// NewMissingTranslationCollector creates an empty collector
+func NewMissingTranslationCollector() *MissingTranslationCollector {
+	c := &MissingTranslationCollector{}
+	c.Reset()
+	return c
+}
+
+// Handle stores a missing translation. It can be passed to WithMissingTranslationHandler.
+func (c *MissingTranslationCollector) Handle(m MissingTranslation) {
+	key := missingKey{domain: m.Domain, context: m.Context, id: m.ID}
+	if key.domain == "" {
+		key.domain = "default"
+	}
+
+	language := m.Language
+	m.Language = ""
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.messages[key]; !ok {
+		m.Domain = key.domain
+		c.messages[key] = &m
+		c.languages[key] = map[string]bool{}
+		c.order = append(c.order, key)
+	}
+
+	// The same message can be used both with and without plural
+	if m.IDPlural != "" {
+		c.messages[key].IDPlural = m.IDPlural
+	}
+	c.languages[key][language] = true
+}
+
+// Domains returns the domains that have missing translations, sorted by name
+func (c *MissingTranslationCollector) Domains() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[string]bool{}
+	var domains []string
+	for _, key := range c.order {
+		if !seen[key.domain] {
+			seen[key.domain] = true
+			domains = append(domains, key.domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Missing returns all collected messages, in the order they were first reported.
+// The Language-field is not set, since each message is only stored once.
+func (c *MissingTranslationCollector) Missing() []MissingTranslation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	missing := make([]MissingTranslation, 0, len(c.order))
+	for _, key := range c.order {
+		missing = append(missing, *c.messages[key])
+	}
+	return missing
+}
+
+// Reset removes all collected messages
+func (c *MissingTranslationCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = map[missingKey]*MissingTranslation{}
+	c.languages = map[missingKey]map[string]bool{}
+	c.order = nil
+}
+
+// Catalog returns the messages collected for a domain as a catalog template.
+// The languages each message was missing in are added as extracted comments.
+func (c *MissingTranslationCollector) Catalog(domain string) *catalog.Catalog {
+	if domain == "" {
+		domain = "default"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cat := catalog.NewTemplate()
+
+	for _, key := range c.order {
+		if key.domain != domain || key.id == "" {
+			continue
+		}
+
+		var languages []string
+		for language := range c.languages[key] {
+			if language != "" {
+				languages = append(languages, language)
+			}
+		}
+		sort.Strings(languages)
+
+		m := c.messages[key]
+		msg := &catalog.Message{
+			Context:  m.Context,
+			ID:       m.ID,
+			IDPlural: m.IDPlural,
+		}
+		if len(languages) > 0 {
+			msg.ExtractedComments = []string{"Missing in: " + strings.Join(languages, ", ")}
+		}
+		cat.Add(msg)
+	}
+	return cat
+}
+
+// WritePot writes the messages collected for a domain to w, in .pot-format
+func (c *MissingTranslationCollector) WritePot(w io.Writer, domain string) error {
+	return catalog.WritePo(w, c.Catalog(domain))
+}
@@ -0,0 +1,95 @@
+package trans
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestTemplateTranslator_MissingTranslationHandler(t *testing.T) {
+	var mu sync.Mutex
+	var missing []MissingTranslation
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales",
+		WithMissingTranslationHandler(func(m MissingTranslation) {
+			mu.Lock()
+			missing = append(missing, m)
+			mu.Unlock()
+		}))
+	require.Nil(t, err)
+
+	sv := TransCtx{Language: "sv_SE"}
+	require.Equal(t, "Hej världen!", tt.Get(sv, "Hello world!"))
+	require.Equal(t, "", tt.Get(sv, ""))
+	require.Len(t, missing, 0)
+
+	require.Equal(t, "Missing", tt.Get(sv, "Missing"))
+	require.Equal(t, "Missing", tt.GetC(TransCtx{Language: "en_GB", Domain: "other"}, "Missing", "ctx"))
+	require.Equal(t, "files", tt.GetN(TransCtx{Language: "de"}, "file", "files", 2))
+	require.Equal(t, "file", tt.GetNC(sv, "file", "files", 1, "ctx"))
+
+	require.Equal(t, []MissingTranslation{
+		{Language: "sv_SE", ID: "Missing"},
+		{Language: "en_GB", Domain: "other", Context: "ctx", ID: "Missing"},
+		{Language: "de", ID: "file", IDPlural: "files"},
+		{Language: "sv_SE", Context: "ctx", ID: "file", IDPlural: "files"},
+	}, missing)
+}
+
+func TestMissingTranslationCollector(t *testing.T) {
+	collector := NewMissingTranslationCollector()
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales", WithMissingTranslationHandler(collector.Handle))
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("trans", NewTransTag(tt))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(tt))
+	}
+	require.Nil(t, err)
+
+	tmpl, err := pongo2.FromString(`{% trans "Hello world!" %} {% trans "Missing" %} {% trans "May" context "month" %}`)
+	require.Nil(t, err)
+
+	for _, language := range []string{"sv_SE", "en_GB", "sv_SE"} {
+		_, err = tmpl.Execute(pongo2.Context{"_language": language})
+		require.Nil(t, err)
+	}
+	tt.GetN(TransCtx{Language: "sv_SE"}, "Missing", "Missing plural", 2)
+	tt.Get(TransCtx{Language: "sv_SE", Domain: "other"}, "Other")
+
+	// Each message should only be collected once
+	require.Equal(t, []MissingTranslation{
+		{Domain: "default", ID: "Missing", IDPlural: "Missing plural"},
+		{Domain: "default", Context: "month", ID: "May"},
+		{Domain: "default", ID: "Hello world!"},
+		{Domain: "other", ID: "Other"},
+	}, collector.Missing())
+	require.Equal(t, []string{"default", "other"}, collector.Domains())
+
+	var buf bytes.Buffer
+	require.Nil(t, collector.WritePot(&buf, "default"))
+
+	cat, err := catalog.ParsePo(buf.Bytes())
+	require.Nil(t, err)
+	require.Len(t, cat.Messages, 4)
+	require.True(t, cat.HeaderMessage().IsFuzzy())
+	require.Equal(t, "text/plain; charset=UTF-8", cat.Header("Content-Type"))
+
+	m := cat.Lookup("", "Hello world!")
+	require.NotNil(t, m)
+	require.Equal(t, []string{"Missing in: en_GB"}, m.ExtractedComments)
+
+	m = cat.Lookup("", "Missing")
+	require.NotNil(t, m)
+	require.Equal(t, "Missing plural", m.IDPlural)
+	require.Equal(t, []string{"Missing in: en_GB, sv_SE"}, m.ExtractedComments)
+
+	require.NotNil(t, cat.Lookup("month", "May"))
+	require.Nil(t, cat.Lookup("", "Other"))
+
+	collector.Reset()
+	require.Len(t, collector.Missing(), 0)
+}
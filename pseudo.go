@@ -0,0 +1,150 @@
+package trans
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// pseudoProtected matches the parts of a translation that must be left untouched by pseudo-localization:
+// template variables, tags and comments, HTML tags, comments and entities, and printf verbs
+var pseudoProtected = regexp.MustCompile(templateSyntax.String() + `|<!--.*?-->|</?[a-zA-Z][^>]*>|&(#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);|%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+// pseudoReplacer replaces ASCII letters with accented versions, which are still readable
+var pseudoReplacer = strings.NewReplacer(
+	"a", "ȧ", "b", "ƀ", "c", "ƈ", "d", "ḓ", "e", "ḗ", "f", "ƒ", "g", "ɠ", "h", "ħ", "i", "ī",
+	"j", "ĵ", "k", "ķ", "l", "ŀ", "m", "ḿ", "n", "ƞ", "o", "ǿ", "p", "ƥ", "q", "ɋ", "r", "ř",
+	"s", "ş", "t", "ŧ", "u", "ŭ", "v", "ṽ", "w", "ẇ", "x", "ẋ", "y", "ẏ", "z", "ẑ",
+	"A", "Ȧ", "B", "Ɓ", "C", "Ƈ", "D", "Ḓ", "E", "Ḗ", "F", "Ƒ", "G", "Ɠ", "H", "Ħ", "I", "Ī",
+	"J", "Ĵ", "K", "Ķ", "L", "Ŀ", "M", "Ḿ", "N", "Ƞ", "O", "Ǿ", "P", "Ƥ", "Q", "Ɋ", "R", "Ř",
+	"S", "Ş", "T", "Ŧ", "U", "Ŭ", "V", "Ṽ", "W", "Ẇ", "X", "Ẋ", "Y", "Ẏ", "Z", "Ẑ",
+)
+
+// PseudoTranslator wraps a Translator, and converts all translations to a pseudo-language
+// to make it easy to spot strings that are not translated, and layouts that can't handle longer texts.
+// Translations to all other languages are passed on to the wrapped translator as is.
+//
+// Pseudo-localized text is written with accented characters, made about 30% longer, and surrounded by brackets,
+// e.g. 'Hello world!' becomes '[Ħḗŀŀǿ ẇǿřŀḓ! ~~~~]'.
+// Template variables and tags, HTML tags and entities, and printf verbs are left untouched.
+//
+// The wrapped translator is called with the pseudo-language, so the msgid is normally used as the source text.
+// Messages are not reported to the missing translation handler of the wrapped translator when they're translated
+// to the pseudo-language, since it has no translations of its own.
+// To use the translations of another language instead, configure a fallback:
+//
+//	t, err := trans.NewTemplateTranslator(localeFS, "locales", trans.WithFallback("qps", "en_GB"))
+//	pt := trans.NewPseudoTranslator(t, "qps")
+//	pongo2.RegisterTag("trans", trans.NewTransTag(pt))
+type PseudoTranslator struct {
+	translator Translator
+	language   string
+}
+
+// NewPseudoTranslator creates a translator that pseudo-localizes all translations to language, e.g. 'qps'
+func NewPseudoTranslator(translator Translator, language string) *PseudoTranslator {
+	return &PseudoTranslator{
+		translator: translator,
+		language:   language,
+	}
+}
+
+// Get translates a string
+func (p *PseudoTranslator) Get(ctx TransCtx, str string, values ...interface{}) string {
+	if ctx.Language != p.language {
+		return p.translator.Get(ctx, str, values...)
+	}
+	ctx.pseudo = true
+	return printf(Pseudolocalize(p.translator.Get(ctx, str)), values...)
+}
+
+// GetC translates a string, with a specific translation context
+func (p *PseudoTranslator) GetC(ctx TransCtx, str string, transCtx string, values ...interface{}) string {
+	if ctx.Language != p.language {
+		return p.translator.GetC(ctx, str, transCtx, values...)
+	}
+	ctx.pseudo = true
+	return printf(Pseudolocalize(p.translator.GetC(ctx, str, transCtx)), values...)
+}
+
+// GetN translates a string, with support for plurals
+func (p *PseudoTranslator) GetN(ctx TransCtx, str string, plural string, count int, values ...interface{}) string {
+	if ctx.Language != p.language {
+		return p.translator.GetN(ctx, str, plural, count, values...)
+	}
+	ctx.pseudo = true
+	return printf(Pseudolocalize(p.translator.GetN(ctx, str, plural, count)), values...)
+}
+
+// GetNC translates a string, with a specific translation context, with support for plurals
+func (p *PseudoTranslator) GetNC(ctx TransCtx, str string, plural string, count int, transCtx string, values ...interface{}) string {
+	if ctx.Language != p.language {
+		return p.translator.GetNC(ctx, str, plural, count, transCtx, values...)
+	}
+	ctx.pseudo = true
+	return printf(Pseudolocalize(p.translator.GetNC(ctx, str, plural, count, transCtx)), values...)
+}
+
+// Languages returns the languages of the wrapped translator, if it's a LanguageProvider, followed by the pseudo-language
+func (p *PseudoTranslator) Languages() []LanguageInfo {
+	var languages []LanguageInfo
+	if provider, ok := p.translator.(LanguageProvider); ok {
+		languages = provider.Languages()
+	}
+	return append(languages, p.LanguageInfo(p.language))
+}
+
+// LanguageInfo returns information about a language
+func (p *PseudoTranslator) LanguageInfo(code string) LanguageInfo {
+	if code == p.language {
+		info := newLanguageInfo(code)
+		info.Name = "Pseudo-language"
+		info.NameLocal = Pseudolocalize(info.Name)
+		info.NameTranslated = info.Name
+		return info
+	}
+
+	if provider, ok := p.translator.(LanguageProvider); ok {
+		return provider.LanguageInfo(code)
+	}
+	return newLanguageInfo(code)
+}
+
+// OnReload registers a function that is called every time the translations of the wrapped translator are reloaded
+func (p *PseudoTranslator) OnReload(fn func()) {
+	if notifier, ok := p.translator.(ReloadNotifier); ok {
+		notifier.OnReload(fn)
+	}
+}
+
+// Pseudolocalize converts str to pseudo-localized text, e.g. 'Hello world!' becomes '[Ħḗŀŀǿ ẇǿřŀḓ! ~~~~]'.
+// Template variables and tags, HTML tags and entities, and printf verbs are left untouched.
+func Pseudolocalize(str string) string {
+	if str == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[")
+
+	length := 0
+	pos := 0
+	for _, loc := range pseudoProtected.FindAllStringIndex(str, -1) {
+		text := str[pos:loc[0]]
+		length += utf8.RuneCountInString(text)
+		sb.WriteString(pseudoReplacer.Replace(text))
+		sb.WriteString(str[loc[0]:loc[1]])
+		pos = loc[1]
+	}
+	text := str[pos:]
+	length += utf8.RuneCountInString(text)
+	sb.WriteString(pseudoReplacer.Replace(text))
+
+	// Expand the text by about 30%, to simulate languages with longer words
+	if padding := (length*3 + 9) / 10; padding > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(strings.Repeat("~", padding))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+package trans
+
+import (
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudolocalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "", expected: ""},
+		{input: "Hello world!", expected: "[Ħḗŀŀǿ ẇǿřŀḓ! ~~~~]"},
+		{input: "Hi {{ name }}", expected: "[Ħī {{ name }} ~]"},
+		{input: "{% if a %}yes{% endif %}{# comment #}", expected: "[{% if a %}ẏḗş{% endif %}{# comment #} ~]"},
+		{input: `Click <a href="/about">here</a> &amp; &#169;`, expected: `[Ƈŀīƈķ <a href="/about">ħḗřḗ</a> &amp; &#169; ~~~~]`},
+		{input: "a < b", expected: "[ȧ < ƀ ~~]"},
+		{input: "%d files in %s, %[1]d%% done", expected: "[%d ƒīŀḗş īƞ %s, %[1]d%% ḓǿƞḗ ~~~~~~]"},
+		{input: "Åäö", expected: "[Åäö ~]"},
+	}
+
+	for k, tst := range tests {
+		require.Equalf(t, tst.expected, Pseudolocalize(tst.input), "test: %d, input: %s", k, tst.input)
+	}
+}
+
+func TestPseudoTranslator(t *testing.T) {
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales", WithFallback("qps-sv", "sv_SE"))
+	require.Nil(t, err)
+
+	pt := NewPseudoTranslator(tt, "qps")
+	ptSV := NewPseudoTranslator(tt, "qps-sv")
+
+	// Other languages should not be changed
+	require.Equal(t, "Hej världen!", pt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+	require.Equal(t, "2 files", pt.GetN(TransCtx{Language: "sv_SE"}, "%d file", "%d files", 2, 2))
+
+	qps := TransCtx{Language: "qps"}
+	require.Equal(t, "[Ħḗŀŀǿ ẇǿřŀḓ! ~~~~]", pt.Get(qps, "Hello world!"))
+	require.Equal(t, "[Ḿȧẏ ~]", pt.GetC(qps, "May", "month"))
+	// Values are inserted after the text has been converted
+	require.Equal(t, "[2 ƒīŀḗş ~~]", pt.GetN(qps, "%d file", "%d files", 2, 2))
+	require.Equal(t, "[1 ƒīŀḗ ~~]", pt.GetNC(qps, "%d file", "%d files", 1, "ctx", 1))
+	require.Equal(t, "[Ħḗŀŀǿ bob ~~]", pt.Get(qps, "Hello %s", "bob"))
+
+	// Translations can be used as the source text by using fallbacks
+	require.Equal(t, "[Ħḗĵ ṽäřŀḓḗƞ! ~~~~]", ptSV.Get(TransCtx{Language: "qps-sv"}, "Hello world!"))
+
+	// The pseudo-language should be listed together with the languages of the wrapped translator
+	languages := pt.Languages()
+	require.Len(t, languages, 3)
+	require.Equal(t, "qps", languages[2].Code)
+	require.Equal(t, "Pseudo-language", languages[2].Name)
+	require.Equal(t, "sv_SE", pt.LanguageInfo("sv_SE").Code)
+	require.Equal(t, 2, pt.LanguageInfo("sv_SE").NPlurals)
+
+	// Template variables in translations should be left untouched
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(pt))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(pt))
+	}
+	require.Nil(t, err)
+
+	tmpl, err := pongo2.FromString(`{% blocktrans with name=user %}Hello {{ name }}{% endblocktrans %}`)
+	require.Nil(t, err)
+	result, err := tmpl.Execute(pongo2.Context{"_language": "qps", "user": "Bob"})
+	require.Nil(t, err)
+	require.Equal(t, "[Ħḗŀŀǿ Bob ~~]", result)
+}
+
+func TestPseudoTranslator_Missing(t *testing.T) {
+	var missing []MissingTranslation
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales", WithFallback("qps", "sv_SE"),
+		WithMissingTranslationHandler(func(m MissingTranslation) { missing = append(missing, m) }))
+	require.Nil(t, err)
+	pt := NewPseudoTranslator(tt, "qps")
+
+	// Messages are only reported for the real languages, not again for the pseudo-language
+	require.Equal(t, "Untranslated", pt.Get(TransCtx{Language: "sv_SE"}, "Untranslated"))
+	require.Equal(t, "[Ŭƞŧřȧƞşŀȧŧḗḓ ~~~~]", pt.Get(TransCtx{Language: "qps"}, "Untranslated"))
+	require.Equal(t, "[2 ƒīŀḗş ~~]", pt.GetNC(TransCtx{Language: "qps"}, "%d file", "%d files", 2, "missing", 2))
+	require.Equal(t, []MissingTranslation{{Language: "sv_SE", ID: "Untranslated"}}, missing)
+}
@@ -1,9 +1,12 @@
 package trans
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/flosch/pongo2/v6"
+	"golang.org/x/text/language"
 )
 
 // NewBlockTransTag creates a new pongo2 block translator tag
@@ -16,73 +19,367 @@ import (
 //	{% blocktrans %}This is a block that should be translated.
 //	It can contain newlines and {{variables}}
 //	{% endblocktrans %}
-func NewBlockTransTag(translator Translator) pongo2.TagParser {
+//
+//	// Expressions can be bound to variables with 'with', and combined with 'count', 'context' and 'asvar'
+//	{% blocktrans with amount=article.price count years=i.length context "price" %}
+//	That will cost $ {{ amount }} per year.
+//	{% plural %}
+//	That will cost $ {{ amount }} per {{ years }} years.
+//	{% endblocktrans %}
+//
+//	// Variants can be chosen with 'select'. Each 'case' is translated with a context of its own, e.g. 'gender=female',
+//	// and the main body is used for all other values
+//	{% blocktrans select gender=user.gender with name=user.name %}
+//	{{ name }} updated their profile.
+//	{% case "female" %}
+//	{{ name }} updated her profile.
+//	{% case "male" %}
+//	{{ name }} updated his profile.
+//	{% endblocktrans %}
+//
+//	// Additional counters are translated with the plural category of their value as context, e.g. 'folders=few',
+//	// and cases can be given for the categories that need a text of their own
+//	{% blocktrans count files=files|length count folders=folders|length %}
+//	{{ files }} file in {{ folders }} folders
+//	{% plural %}
+//	{{ files }} files in {{ folders }} folders
+//	{% case folders "one" %}
+//	{{ files }} file in {{ folders }} folder
+//	{% plural %}
+//	{{ files }} files in {{ folders }} folder
+//	{% endblocktrans %}
+//
+// The templates compiled from the translated strings are cached, see TagOption for how the cache can be configured.
+func NewBlockTransTag(translator Translator, options ...TagOption) pongo2.TagParser {
+	o := newTagOptions(options)
+	cache := newTemplateCache(translator, o.cacheSize)
+
+	var categories []string
+	if o.extract != nil {
+		categories = integerCategories(o.extractLanguages)
+	}
+
 	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (tag pongo2.INodeTag, err *pongo2.Error) {
-		transNode := &tagTransNode{translator: translator}
+		transNode := &tagTransNode{
+			translator: translator,
+			cache:      cache,
+			untrusted:  o.untrusted,
+			restricted: o.restricted,
+		}
 
 		transNode.withEval = make(map[string]pongo2.IEvaluator)
 
-		countToken := arguments.Peek(pongo2.TokenIdentifier, "count")
-		if countToken != nil {
-			arguments.Consume()
+		for arguments.Remaining() > 0 {
+			switch {
+			case arguments.Match(pongo2.TokenIdentifier, "with") != nil:
+				if err = parseBindings(arguments, transNode.withEval); err != nil {
+					return nil, err
+				}
 
-			keyToken := arguments.MatchType(pongo2.TokenIdentifier)
-			if keyToken == nil {
-				return nil, arguments.Error("Expected an identifier", nil)
-			}
+			case arguments.Match(pongo2.TokenIdentifier, "count") != nil:
+				var key string
+				key, err = parseBinding(arguments, transNode.withEval)
+				if err != nil {
+					return nil, err
+				}
 
-			if arguments.Match(pongo2.TokenSymbol, "=") == nil {
-				return nil, arguments.Error("Expected '='.", nil)
-			}
+				// The first counter selects the plural form, and the rest are used as variants
+				if transNode.countEval == nil {
+					transNode.countEval = transNode.withEval[key]
+				} else {
+					transNode.counters = append(transNode.counters, key)
+				}
 
-			valueExpr, err := arguments.ParseExpression()
-			if err != nil {
-				return nil, err
-			}
-			transNode.withEval[keyToken.Val] = valueExpr
-			transNode.countEval = valueExpr
-		}
+			case arguments.Match(pongo2.TokenIdentifier, "select") != nil:
+				if transNode.selectName != "" {
+					return nil, arguments.Error("'select' can only be specified once", nil)
+				}
 
-		if t := arguments.Peek(pongo2.TokenIdentifier, "context"); t != nil {
-			arguments.Consume()
-			transCtx := arguments.Current()
+				transNode.selectName, err = parseBinding(arguments, transNode.withEval)
+				if err != nil {
+					return nil, err
+				}
 
-			if transCtx == nil || transCtx.Typ != pongo2.TokenString {
-				return nil, arguments.Error("Expected 'context' to be followed by a string", nil)
-			}
-			transNode.transCtx = transCtx.Val
-		}
+			case arguments.Match(pongo2.TokenIdentifier, "context") != nil:
+				if transNode.transCtx != "" {
+					return nil, arguments.Error("'context' can only be specified once", nil)
+				}
+
+				transCtx := arguments.MatchType(pongo2.TokenString)
+				if transCtx == nil {
+					return nil, arguments.Error("Expected 'context' to be followed by a string", nil)
+				}
+				transNode.transCtx = transCtx.Val
+
+			case arguments.Match(pongo2.TokenIdentifier, "asvar") != nil:
+				if transNode.asValue != "" {
+					return nil, arguments.Error("'asvar' can only be specified once", nil)
+				}
+
+				asTag := arguments.MatchType(pongo2.TokenIdentifier)
+				if asTag == nil {
+					return nil, arguments.Error("Expected 'asvar' to be follow by an identifier", nil)
+				}
+				transNode.asValue = asTag.Val
 
-		if t := arguments.Peek(pongo2.TokenIdentifier, "asvar"); t != nil {
-			arguments.Consume()
-			asTag := arguments.Current()
-			if asTag == nil || asTag.Typ != pongo2.TokenIdentifier {
-				return nil, arguments.Error("Expected 'as' to be follow by an identifier", nil)
+			default:
+				return nil, arguments.Error(fmt.Sprintf("Unknown argument '%s', expected 'with', 'count', 'select', 'context' or 'asvar'", arguments.Current().Val), nil)
 			}
-			arguments.Consume()
-			transNode.asValue = asTag.Val
 		}
 
-		text, endTag, err := getTextUntil(doc, "plural", "endblocktrans")
+		text, endTag, endArgs, err := getTextUntil(doc, "plural", "case", "endblocktrans")
 		if err != nil {
 			return nil, err
 		}
 		transNode.transText = text
 
 		if endTag == "plural" {
-			text, _, err = getTextUntil(doc, "endblocktrans")
+			text, endTag, endArgs, err = getTextUntil(doc, "case", "endblocktrans")
 			if err != nil {
 				return nil, err
 			}
 			transNode.pluralText = text
 		}
 
+		for endTag == "case" {
+			if transNode.selectName == "" && len(transNode.counters) == 0 {
+				return nil, doc.Error("'case' can only be used together with 'select' or additional counters", nil)
+			}
+
+			var variant transVariant
+			variant, err = parseVariant(doc, transNode, endArgs)
+			if err != nil {
+				return nil, err
+			}
+
+			variant.text, endTag, endArgs, err = getTextUntil(doc, "plural", "case", "endblocktrans")
+			if err != nil {
+				return nil, err
+			}
+
+			if endTag == "plural" {
+				variant.plural, endTag, endArgs, err = getTextUntil(doc, "case", "endblocktrans")
+				if err != nil {
+					return nil, err
+				}
+			}
+			transNode.variants = append(transNode.variants, variant)
+		}
+
+		if o.extract != nil && transNode.transEval == nil && transNode.transText != "" {
+			for _, m := range transNode.variantMessages(categories) {
+				m.Filename = start.Filename
+				m.Line = start.Line
+				o.extract(m)
+			}
+		}
 		return transNode, nil
 	}
 	return fn
 }
 
-func getTextUntil(doc *pongo2.Parser, names ...string) (str string, endTagName string, err *pongo2.Error) {
+// parseVariant parses the arguments of a 'case'-tag, i.e. an optional value of 'select',
+// followed by additional counters and their plural categories, e.g. '{% case "female" folders "one" %}'
+func parseVariant(doc *pongo2.Parser, node *tagTransNode, args []*pongo2.Token) (transVariant, *pongo2.Error) {
+	var variant transVariant
+	if len(args) == 0 {
+		return variant, doc.Error("Expected 'case' to be followed by a string, or by counters and plural categories", nil)
+	}
+
+	names := make([]string, len(args))
+	for k, t := range args {
+		names[k] = t.Val
+	}
+
+	if args[0].Typ == pongo2.TokenString {
+		if node.selectName == "" {
+			return variant, doc.Error("A value in 'case' can only be used together with 'select'", nil)
+		}
+		variant.hasValue, variant.value = true, args[0].Val
+		args = args[1:]
+	}
+
+	for len(args) > 0 {
+		if len(args) < 2 || args[0].Typ != pongo2.TokenIdentifier || args[1].Typ != pongo2.TokenString {
+			return variant, doc.Error("Expected counters in 'case' to be followed by a plural category, e.g. 'n \"one\"'", nil)
+		}
+		name, category := args[0].Val, args[1].Val
+		args = args[2:]
+
+		if !isCounter(node, name) {
+			return variant, doc.Error(fmt.Sprintf("'%s' is not an additional counter", name), nil)
+		}
+		if !isPluralCategory(category) {
+			return variant, doc.Error(fmt.Sprintf("Unknown plural category '%s', expected 'zero', 'one', 'two', 'few', 'many' or 'other'", category), nil)
+		}
+		if _, ok := variant.counters[name]; ok {
+			return variant, doc.Error(fmt.Sprintf("Counter '%s' is specified more than once in 'case'", name), nil)
+		}
+
+		if variant.counters == nil {
+			variant.counters = map[string]string{}
+		}
+		variant.counters[name] = category
+	}
+
+	for _, v := range node.variants {
+		if v.hasValue == variant.hasValue && v.value == variant.value && reflect.DeepEqual(v.counters, variant.counters) {
+			return variant, doc.Error(fmt.Sprintf("Case '%s' is specified more than once", strings.Join(names, " ")), nil)
+		}
+	}
+	return variant, nil
+}
+
+func isCounter(node *tagTransNode, name string) bool {
+	for _, counter := range node.counters {
+		if counter == name {
+			return true
+		}
+	}
+	return false
+}
+
+// cldrCategories contains the names of the CLDR plural categories, in the order they are extracted
+var cldrCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+func isPluralCategory(category string) bool {
+	for _, c := range cldrCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// integerCategories returns the plural categories used for whole numbers in any of the languages
+func integerCategories(languages []string) []string {
+	used := map[string]bool{}
+	for _, code := range languages {
+		tag, err := parseLanguage(code)
+		if err != nil {
+			tag = language.Und
+		}
+
+		// The rules only depend on the last few digits, so the first thousand numbers contain all categories
+		for n := 0; n < 1000; n++ {
+			used[pluralCategory(tag, float64(n), false)] = true
+		}
+	}
+
+	var categories []string
+	for _, c := range cldrCategories {
+		if used[c] {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// variantMessages returns the messages of the main body and all variants, with the contexts they are looked up with
+// when the node is executed. Each additional counter is extracted once for every category in categories.
+func (node *tagTransNode) variantMessages(categories []string) []ExtractedMessage {
+	// The main body is used for all values of 'select' without a variant of their own
+	values := []string{""}
+	selected := []bool{false}
+	seen := map[string]bool{}
+	for _, v := range node.variants {
+		if v.hasValue && !seen[v.value] {
+			seen[v.value] = true
+			values = append(values, v.value)
+			selected = append(selected, true)
+		}
+	}
+
+	combinations := []map[string]string{{}}
+	for _, name := range node.counters {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, category := range categories {
+				c := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[name] = category
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	var messages []ExtractedMessage
+	for k, value := range values {
+		for _, combination := range combinations {
+			msgctxt, text, plural := node.variant(value, selected[k], combination)
+			if text != "" {
+				messages = append(messages, ExtractedMessage{Context: msgctxt, ID: text, IDPlural: plural})
+			}
+		}
+	}
+	return messages
+}
+
+// parseBindings parses the variable bindings following 'with', and adds them to bindings.
+// Additional bindings are either separated by 'and' (legacy syntax) or by whitespace
+func parseBindings(arguments *pongo2.Parser, bindings map[string]pongo2.IEvaluator) *pongo2.Error {
+	if _, err := parseBinding(arguments, bindings); err != nil {
+		return err
+	}
+
+	for arguments.Match(pongo2.TokenKeyword, "and") != nil ||
+		(arguments.PeekType(pongo2.TokenIdentifier) != nil && arguments.PeekN(1, pongo2.TokenSymbol, "=") != nil) {
+		if _, err := parseBinding(arguments, bindings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBinding parses a variable binding, either in the form 'key=expr' or in the legacy form 'expr as key',
+// and adds it to bindings. The name of the bound variable is returned.
+func parseBinding(arguments *pongo2.Parser, bindings map[string]pongo2.IEvaluator) (string, *pongo2.Error) {
+	var key string
+	var valueExpr pongo2.IEvaluator
+	var err *pongo2.Error
+
+	if arguments.PeekType(pongo2.TokenIdentifier) != nil && arguments.PeekN(1, pongo2.TokenSymbol, "=") != nil {
+		key = arguments.MatchType(pongo2.TokenIdentifier).Val
+		arguments.Consume() // '='
+
+		valueExpr, err = arguments.ParseExpression()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		if arguments.Remaining() == 0 {
+			return "", arguments.Error("Expected a variable binding in the form 'name=value'", nil)
+		}
+
+		valueExpr, err = arguments.ParseExpression()
+		if err != nil {
+			return "", err
+		}
+
+		if arguments.Match(pongo2.TokenKeyword, "as") == nil {
+			return "", arguments.Error("Expected a variable binding in the form 'name=value' or 'value as name'", nil)
+		}
+
+		keyToken := arguments.MatchType(pongo2.TokenIdentifier)
+		if keyToken == nil {
+			return "", arguments.Error("Expected 'as' to be followed by an identifier", nil)
+		}
+		key = keyToken.Val
+	}
+
+	if _, ok := bindings[key]; ok {
+		return "", arguments.Error(fmt.Sprintf("Variable '%s' is bound more than once", key), nil)
+	}
+	bindings[key] = valueExpr
+	return key, nil
+}
+
+// getTextUntil returns the text until one of the tags in names, and the name and the arguments of the tag found,
+// e.g. the string in '{% case "female" %}'
+func getTextUntil(doc *pongo2.Parser, names ...string) (str string, endTagName string, endTagArgs []*pongo2.Token, err *pongo2.Error) {
 	var prevLine, prevEndCol int
 	for doc.Remaining() > 0 {
 		// New tag, check whether we have to stop wrapping here
@@ -106,12 +403,15 @@ func getTextUntil(doc *pongo2.Parser, names ...string) (str string, endTagName s
 					// Okay, endtag found.
 					doc.ConsumeN(2) // '{%' tagname
 
-					for {
+					for doc.Remaining() > 0 {
 						if doc.Match(pongo2.TokenSymbol, "%}") != nil {
 							// Done skipping, exit.
-							return str, endTagName, nil
+							return str, endTagName, endTagArgs, nil
 						}
+						endTagArgs = append(endTagArgs, doc.Current())
+						doc.Consume()
 					}
+					break
 				}
 			}
 		}
@@ -134,5 +434,5 @@ func getTextUntil(doc *pongo2.Parser, names ...string) (str string, endTagName s
 		prevLine = t.Line
 		prevEndCol = t.Col + len(t.Val)
 	}
-	return str, endTagName, doc.Error("Unexpected EOF.", nil)
+	return str, endTagName, nil, doc.Error("Unexpected EOF.", nil)
 }
@@ -0,0 +1,181 @@
+package trans
+
+import (
+	"github.com/flosch/pongo2/v6"
+)
+
+type tagGetAvailableLanguagesNode struct {
+	provider LanguageProvider
+	asValue  string
+}
+
+func (node *tagGetAvailableLanguagesNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	currentLanguage := getTransCtx(ctx).Language
+
+	languages := node.provider.Languages()
+	for k := range languages {
+		languages[k] = translateLanguageInfo(languages[k], currentLanguage)
+	}
+	ctx.Public[node.asValue] = languages
+	return nil
+}
+
+type tagGetCurrentLanguageNode struct {
+	asValue string
+}
+
+func (node *tagGetCurrentLanguageNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	ctx.Public[node.asValue] = getTransCtx(ctx).Language
+	return nil
+}
+
+type tagGetLanguageInfoNode struct {
+	provider     LanguageProvider
+	languageEval pongo2.IEvaluator
+	asValue      string
+	list         bool
+}
+
+func (node *tagGetLanguageInfoNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	currentLanguage := getTransCtx(ctx).Language
+
+	val, err := node.languageEval.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !node.list {
+		ctx.Public[node.asValue] = translateLanguageInfo(node.provider.LanguageInfo(languageCode(val)), currentLanguage)
+		return nil
+	}
+
+	var languages []LanguageInfo
+	// When iterating over a list, the item is passed as the key
+	val.Iterate(func(idx, count int, key, value *pongo2.Value) bool {
+		languages = append(languages, translateLanguageInfo(node.provider.LanguageInfo(languageCode(key)), currentLanguage))
+		return true
+	}, func() {})
+	ctx.Public[node.asValue] = languages
+	return nil
+}
+
+// languageCode returns the language code of a value, which is either a language code or a LanguageInfo
+func languageCode(val *pongo2.Value) string {
+	switch v := val.Interface().(type) {
+	case LanguageInfo:
+		return v.Code
+	case *LanguageInfo:
+		return v.Code
+	}
+	return val.String()
+}
+
+// parseAsValue parses the 'as <identifier>'-arguments used by tags that store their result in a variable
+func parseAsValue(tagName string, arguments *pongo2.Parser) (string, *pongo2.Error) {
+	if arguments.Match(pongo2.TokenKeyword, "as") == nil {
+		return "", arguments.Error("Tag '"+tagName+"' requires 'as' followed by an identifier", nil)
+	}
+
+	asTag := arguments.MatchType(pongo2.TokenIdentifier)
+	if asTag == nil {
+		return "", arguments.Error("Expected 'as' to be follow by an identifier", nil)
+	}
+
+	if arguments.Remaining() > 0 {
+		return "", arguments.Error("Malformed '"+tagName+"'-tag arguments.", nil)
+	}
+	return asTag.Val, nil
+}
+
+// NewGetAvailableLanguagesTag creates a pongo2 tag that stores a list of all available languages in a variable
+//
+// Usage:
+//
+//	pongo2.RegisterTag("get_available_languages", trans.NewGetAvailableLanguagesTag(tr))
+//
+//	// and then, in your templates
+//	{% get_available_languages as langs %}
+//	{% for lang in langs %}<option value="{{ lang.Code }}">{{ lang.Name }}</option>{% endfor %}
+func NewGetAvailableLanguagesTag(provider LanguageProvider) pongo2.TagParser {
+	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (tag pongo2.INodeTag, err *pongo2.Error) {
+		node := &tagGetAvailableLanguagesNode{provider: provider}
+
+		node.asValue, err = parseAsValue("get_available_languages", arguments)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return fn
+}
+
+// NewGetCurrentLanguageTag creates a pongo2 tag that stores the current language code in a variable
+//
+// Usage:
+//
+//	pongo2.RegisterTag("get_current_language", trans.NewGetCurrentLanguageTag())
+//
+//	// and then, in your templates
+//	{% get_current_language as lang %}<html lang="{{ lang }}">
+func NewGetCurrentLanguageTag() pongo2.TagParser {
+	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (tag pongo2.INodeTag, err *pongo2.Error) {
+		node := &tagGetCurrentLanguageNode{}
+
+		node.asValue, err = parseAsValue("get_current_language", arguments)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return fn
+}
+
+// NewGetLanguageInfoTag creates a pongo2 tag that stores information about a language in a variable
+//
+// Usage:
+//
+//	pongo2.RegisterTag("get_language_info", trans.NewGetLanguageInfoTag(tr))
+//
+//	// and then, in your templates
+//	{% get_language_info for "sv_SE" as info %}
+//	<html lang="{{ info.Code }}" dir="{{ info.Direction }}">{{ info.NameLocal }}
+func NewGetLanguageInfoTag(provider LanguageProvider) pongo2.TagParser {
+	return newGetLanguageInfoTag("get_language_info", provider, false)
+}
+
+// NewGetLanguageInfoListTag creates a pongo2 tag that stores information about a list of languages in a variable.
+// The list can contain either language codes or LanguageInfo-values
+//
+// Usage:
+//
+//	pongo2.RegisterTag("get_language_info_list", trans.NewGetLanguageInfoListTag(tr))
+//
+//	// and then, in your templates
+//	{% get_available_languages as langs %}
+//	{% get_language_info_list for langs as infos %}
+//	{% for info in infos %}{{ info.NameLocal }} ({{ info.NameTranslated }}){% endfor %}
+func NewGetLanguageInfoListTag(provider LanguageProvider) pongo2.TagParser {
+	return newGetLanguageInfoTag("get_language_info_list", provider, true)
+}
+
+func newGetLanguageInfoTag(tagName string, provider LanguageProvider, list bool) pongo2.TagParser {
+	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (tag pongo2.INodeTag, err *pongo2.Error) {
+		node := &tagGetLanguageInfoNode{provider: provider, list: list}
+
+		if arguments.Match(pongo2.TokenIdentifier, "for") == nil {
+			return nil, arguments.Error("Tag '"+tagName+"' requires 'for' followed by a language", nil)
+		}
+
+		node.languageEval, err = arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		node.asValue, err = parseAsValue(tagName, arguments)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return fn
+}
@@ -0,0 +1,88 @@
+package trans
+
+import (
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagGetLanguage_Execute(t *testing.T) {
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales")
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("get_available_languages", NewGetAvailableLanguagesTag(tt))
+	if err != nil {
+		err = pongo2.ReplaceTag("get_available_languages", NewGetAvailableLanguagesTag(tt))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("get_current_language", NewGetCurrentLanguageTag())
+	if err != nil {
+		err = pongo2.ReplaceTag("get_current_language", NewGetCurrentLanguageTag())
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("get_language_info", NewGetLanguageInfoTag(tt))
+	if err != nil {
+		err = pongo2.ReplaceTag("get_language_info", NewGetLanguageInfoTag(tt))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("get_language_info_list", NewGetLanguageInfoListTag(tt))
+	if err != nil {
+		err = pongo2.ReplaceTag("get_language_info_list", NewGetLanguageInfoListTag(tt))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("language", NewLanguageTag())
+	if err != nil {
+		err = pongo2.ReplaceTag("language", NewLanguageTag())
+	}
+	require.Nil(t, err)
+
+	type T struct {
+		input    string
+		expected string
+		err      bool
+	}
+
+	tests := []T{
+		{input: `{% get_available_languages as langs %}{% for l in langs %}{{ l.Code }}={{ l.Name }};{% endfor %}`, expected: "en_GB=British English;sv_SE=Swedish (Sweden);"},
+		{input: `{% get_available_languages as langs %}{% for l in langs %}{{ l.NameTranslated }};{% endfor %}`, expected: "brittisk engelska;svenska (Sverige);"},
+		{input: `{% get_current_language as lang %}{{ lang }}`, expected: "sv_SE"},
+		{input: `{% for i in "ab" %}{% get_current_language as current %}{% endfor %}{{ current }}`, expected: "sv_SE"},
+		{input: `{% for i in "ab" %}{% get_language_info for "en_GB" as info %}{% endfor %}{{ info.Code }}`, expected: "en_GB"},
+		{input: `{% language "en_GB" %}{% get_current_language as lang %}{{ lang }}{% endlanguage %}`, expected: "en_GB"},
+		{input: `{% get_language_info for "en_GB" as info %}{{ info.Code }} {{ info.Name }} {{ info.NameLocal }} {{ info.NameTranslated }} {{ info.Direction }} {{ info.NPlurals }}`, expected: "en_GB British English British English brittisk engelska ltr 2"},
+		{input: `{% get_language_info for "ar" as info %}{{ info.NameLocal }} {{ info.Bidi }} {{ info.Direction }}`, expected: "العربية True rtl"},
+		{input: `{% get_language_info for lang as info %}{{ info.Name }}`, expected: "Hebrew"},
+		{input: `{% language "en_GB" %}{% get_language_info for "sv_SE" as info %}{{ info.NameTranslated }}{% endlanguage %}`, expected: "Swedish (Sweden)"},
+		{input: `{% get_language_info_list for codes as infos %}{% for i in infos %}{{ i.NameLocal }};{% endfor %}`, expected: "svenska (Sverige);עברית;"},
+		{input: `{% get_available_languages as langs %}{% get_language_info_list for langs as infos %}{% for i in infos %}{{ i.Code }}:{{ i.NPlurals }};{% endfor %}`, expected: "en_GB:2;sv_SE:2;"},
+		{input: `{% get_available_languages %}`, err: true},
+		{input: `{% get_language_info "sv_SE" as info %}`, err: true},
+		{input: `{% get_language_info for "sv_SE" %}`, err: true},
+		{input: `{% get_language_info_list for codes as %}`, err: true},
+		{input: `{% get_available_languages as %}`, err: true},
+		{input: `{% get_available_languages as langs other %}`, err: true},
+		{input: `{% get_current_language lang %}`, err: true},
+	}
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		result, err := tmpl.Execute(pongo2.Context{
+			"_language": "sv_SE",
+			"lang":      "he",
+			"codes":     []string{"sv_SE", "he"},
+		})
+		require.Nilf(t, err, "test: %d input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d input: %s", k, tst.input)
+	}
+}
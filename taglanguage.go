@@ -0,0 +1,65 @@
+package trans
+
+import (
+	"github.com/flosch/pongo2/v6"
+)
+
+type tagLanguageNode struct {
+	languageEval pongo2.IEvaluator
+	wrapper      *pongo2.NodeWrapper
+}
+
+func (node *tagLanguageNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	val, err := node.languageEval.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Everything inside the block is rendered in a child context,
+	// so that the language is restored when the block ends
+	langCtx := pongo2.NewChildExecutionContext(ctx)
+	langCtx.Private["_language"] = val.String()
+
+	return node.wrapper.Execute(langCtx, writer)
+}
+
+// NewLanguageTag creates a pongo2 tag that overrides the language used for
+// all translations inside the block
+//
+// Usage:
+//
+//	pongo2.RegisterTag("language", trans.NewLanguageTag())
+//
+//	// and then, in your templates
+//	{% language "sv_SE" %}{% trans "This will be translated to swedish" %}{% endlanguage %}
+//	{% language lang.Code %}{% include "footer.html" %}{% endlanguage %}
+func NewLanguageTag() pongo2.TagParser {
+	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (tag pongo2.INodeTag, err *pongo2.Error) {
+		languageNode := &tagLanguageNode{}
+
+		if arguments.Remaining() == 0 {
+			return nil, arguments.Error("Tag 'language' requires one argument, which must be a string or identifier", nil)
+		}
+
+		languageNode.languageEval, err = arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Malformed 'language'-tag arguments.", nil)
+		}
+
+		wrapper, endArgs, err := doc.WrapUntilTag("endlanguage")
+		if err != nil {
+			return nil, err
+		}
+		if endArgs.Count() > 0 {
+			return nil, endArgs.Error("Arguments not allowed here.", nil)
+		}
+		languageNode.wrapper = wrapper
+
+		return languageNode, nil
+	}
+	return fn
+}
@@ -0,0 +1,96 @@
+package trans
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagLanguageNode_Execute(t *testing.T) {
+	testTrans := TestTranslator{}
+	err := pongo2.RegisterTag("trans", NewTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("language", NewLanguageTag())
+	if err != nil {
+		err = pongo2.ReplaceTag("language", NewLanguageTag())
+	}
+	require.Nil(t, err)
+
+	type T struct {
+		input    string
+		expected string
+		err      bool
+	}
+
+	tests := []T{
+		{input: `{% language "sv_SE" %}{% trans "test" %}{% endlanguage %}`, expected: "domain:sv_SE:test"},
+		{input: `{% language "sv_SE" %}{% blocktrans %}test{% endblocktrans %}{% endlanguage %}`, expected: "domain:sv_SE:test"},
+		{input: `{% language lang %}{% trans "test" %}{% endlanguage %}`, expected: "domain:en_GB:test"},
+		{input: `{% language "sv_SE" %}{% trans "a" %}{% endlanguage %} {% trans "b" %}`, expected: "domain:sv_SE:a domain:language:b"},
+		{input: `{% language "sv_SE" %}{% language "en_GB" %}{% trans "a" %}{% endlanguage %} {% trans "b" %}{% endlanguage %}`, expected: "domain:en_GB:a domain:sv_SE:b"},
+		{input: `{% language %}{% endlanguage %}`, err: true},
+		{input: `{% language "sv_SE" "en_GB" %}{% endlanguage %}`, err: true},
+		{input: `{% language "sv_SE" %}{% endlanguage "sv_SE" %}`, err: true},
+		{input: `{% language "sv_SE" %}`, err: true},
+	}
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		result, err := tmpl.Execute(pongo2.Context{
+			"_domain":   "domain",
+			"_language": "language",
+			"lang":      "en_GB",
+		})
+		require.Nilf(t, err, "test: %d input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d input: %s", k, tst.input)
+	}
+}
+
+// Check that the language is passed on to included templates
+func TestTagLanguageNode_Include(t *testing.T) {
+	testTrans := TestTranslator{}
+	err := pongo2.RegisterTag("trans", NewTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("language", NewLanguageTag())
+	if err != nil {
+		err = pongo2.ReplaceTag("language", NewLanguageTag())
+	}
+	require.Nil(t, err)
+
+	ts := pongo2.NewSet("language", pongo2.NewFSLoader(fstest.MapFS{
+		"footer.html": &fstest.MapFile{Data: []byte(`{% trans "footer" %}`)},
+		"index.html":  &fstest.MapFile{Data: []byte(`{% include "footer.html" %} {% language "sv_SE" %}{% include "footer.html" %}{% endlanguage %}`)},
+	}))
+
+	tmpl, err := ts.FromFile("index.html")
+	require.Nil(t, err)
+
+	result, err := tmpl.Execute(pongo2.Context{
+		"_domain":   "domain",
+		"_language": "en_GB",
+	})
+	require.Nil(t, err)
+	require.Equal(t, "domain:en_GB:footer domain:sv_SE:footer", result)
+}
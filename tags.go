@@ -1,15 +1,32 @@
 package trans
 
+import (
+	"github.com/flosch/pongo2/v6"
+)
+
 // TransCtx describes a translation context specifying the language and domain to use when translating
 type TransCtx struct {
 	Language string
 	Domain   string
+
+	// Args contains the variables bound by the 'trans' and 'blocktrans' tags with 'with' and 'count',
+	// for translators that format the translations themselves, e.g. ICUTranslator
+	Args map[string]interface{}
+
+	// pseudo is set by PseudoTranslator when translating to the pseudo-language. The pseudo-language never has
+	// translations of its own, so messages missing in it are not reported to the missing translation handler
+	pseudo bool
 }
 
-// LanguageInfo describes a language.  It's used by the 'language' and 'get_available_languages'-tags
+// LanguageInfo describes a language.  It's used by the 'get_available_languages' and 'get_language_info'-tags
 type LanguageInfo struct {
-	Code string
-	Name string
+	Code           string
+	Name           string // Name of the language in english
+	NameLocal      string // Name of the language in the language itself
+	NameTranslated string // Name of the language in the current language
+	Bidi           bool   // Is the language written from right to left?
+	Direction      string // Text direction, 'ltr' or 'rtl'
+	NPlurals       int    // Number of plural forms, as specified in the catalog header
 }
 
 // Translator describes the interface that a translator implementation must fulfill
@@ -19,3 +36,111 @@ type Translator interface {
 	GetN(ctx TransCtx, str string, plural string, count int, values ...interface{}) string
 	GetNC(ctx TransCtx, str string, plural string, count int, transCtx string, values ...interface{}) string
 }
+
+// LanguageProvider describes a source of available languages, e.g. a TemplateTranslator
+type LanguageProvider interface {
+	Languages() []LanguageInfo
+	LanguageInfo(code string) LanguageInfo
+}
+
+// TagOption configures optional behaviour of the 'trans' and 'blocktrans' tags
+type TagOption func(o *tagOptions)
+
+type tagOptions struct {
+	cacheSize  int
+	untrusted  bool
+	restricted bool
+	extract    func(m ExtractedMessage)
+
+	extractLanguages []string
+}
+
+func newTagOptions(options []TagOption) tagOptions {
+	o := tagOptions{cacheSize: defaultTemplateCacheSize, extractLanguages: []string{"en"}}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// WithTemplateCacheSize sets the maximum number of compiled translations kept in the cache. Defaults to 10000.
+// A size of 0 disables the cache, so that translated strings are parsed every time they are rendered.
+func WithTemplateCacheSize(size int) TagOption {
+	return func(o *tagOptions) {
+		o.cacheSize = size
+	}
+}
+
+// WithoutTemplateCache disables the cache of compiled translations
+func WithoutTemplateCache() TagOption {
+	return WithTemplateCacheSize(0)
+}
+
+// WithUntrustedTranslations escapes the text of the translations when autoescaping is enabled.
+// By default translations are trusted, so that they can contain markup, e.g. 'Click <a href="{{ url }}">here</a>'.
+// Variables in the translations are escaped regardless of this option.
+//
+// Since untrusted translations could otherwise use tags or filters to output unescaped markup,
+// e.g. '{{ "<script>"|safe }}' or '{% autoescape off %}', this implies WithRestrictedInterpolation.
+func WithUntrustedTranslations() TagOption {
+	return func(o *tagOptions) {
+		o.untrusted = true
+		o.restricted = true
+	}
+}
+
+// WithRestrictedInterpolation only allows translations to use the variables used in the original message,
+// or bound by the tag with 'with' or 'count'. Template tags are not allowed in the translations.
+// Translations that don't follow these rules are not rendered, and an error is returned instead.
+// See ValidateTranslation for details.
+func WithRestrictedInterpolation() TagOption {
+	return func(o *tagOptions) {
+		o.restricted = true
+	}
+}
+
+// ExtractedMessage describes a message found by the 'trans' or 'blocktrans'-tags when a template is parsed
+type ExtractedMessage struct {
+	Context  string
+	ID       string
+	IDPlural string
+
+	Filename string // Name of the template, as given by pongo2
+	Line     int
+}
+
+// WithExtractor calls fn for every message found when templates are parsed, e.g. to extract messages for translation.
+// Messages are extracted exactly as they are looked up when the template is executed.
+// Strings that are only known when the template is executed, e.g. '{% trans name %}', are not extracted.
+func WithExtractor(fn func(m ExtractedMessage)) TagOption {
+	return func(o *tagOptions) {
+		o.extract = fn
+	}
+}
+
+// WithExtractedLanguages sets the languages whose plural categories are extracted for the additional counters
+// of the 'blocktrans'-tag, e.g. 'ru' extracts the categories 'one', 'few' and 'many'. Defaults to 'en', i.e. 'one' and 'other'.
+func WithExtractedLanguages(languages ...string) TagOption {
+	return func(o *tagOptions) {
+		o.extractLanguages = languages
+	}
+}
+
+// getTransCtx returns the translation context for the current execution.
+// Private values (e.g. set by the 'language'-tag) take precedence over public ones.
+func getTransCtx(ctx *pongo2.ExecutionContext) TransCtx {
+	language, ok := ctx.Private["_language"].(string)
+	if !ok {
+		language, _ = ctx.Public["_language"].(string)
+	}
+
+	domain, ok := ctx.Private["_domain"].(string)
+	if !ok {
+		domain, _ = ctx.Public["_domain"].(string)
+	}
+
+	return TransCtx{
+		Language: language,
+		Domain:   domain,
+	}
+}
@@ -1,11 +1,29 @@
 package trans
 
 import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/flosch/pongo2/v6"
+	"golang.org/x/text/language"
 )
 
+// templateMutex serializes the parsing of translated strings,
+// since pongo2 modifies the template set every time a template is created
+var templateMutex sync.Mutex
+
+// templateSyntax matches template variables, tags and comments in a translated string
+var templateSyntax = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{%.*?%\}|\{#.*?#\}`)
+
 type tagTransNode struct {
 	translator Translator
+	cache      *templateCache
+	untrusted  bool
+	restricted bool
 
 	countEval pongo2.IEvaluator
 	withEval  map[string]pongo2.IEvaluator
@@ -17,76 +35,192 @@ type tagTransNode struct {
 	transText  string
 	transEval  pongo2.IEvaluator
 	pluralText string
+
+	// The variable bound with 'select', and the variants given with 'case' in blocktrans
+	selectName string
+	variants   []transVariant
+
+	// The variables bound by all but the first 'count' in blocktrans, in the order they were given
+	counters []string
 }
 
-func (node *tagTransNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) (transError *pongo2.Error) {
-	language, ok := ctx.Private["_language"].(string)
-	if !ok {
-		language, _ = ctx.Public["_language"].(string)
+// transVariant is a variant of a blocktrans-tag with 'select' or additional counters,
+// e.g. '{% case "female" %}' or '{% case folders "one" %}'
+type transVariant struct {
+	hasValue bool   // Is the variant used for a value of 'select'?
+	value    string // The value of 'select'
+	counters map[string]string
+
+	text   string
+	plural string
+}
+
+// matches checks if the variant should be used for a value of 'select' and the plural categories of the counters.
+// selected is false if no variant exists for the value of 'select'. Conditions not given by the variant matches everything.
+func (v transVariant) matches(value string, selected bool, categories map[string]string) bool {
+	if v.hasValue && (!selected || v.value != value) {
+		return false
 	}
+	for name, category := range v.counters {
+		if categories[name] != category {
+			return false
+		}
+	}
+	return true
+}
 
-	domain, ok := ctx.Private["_domain"].(string)
-	if !ok {
-		domain, _ = ctx.Public["_domain"].(string)
+// SelectTranslator is implemented by translators that choose between variants themselves, using the values in TransCtx.Args,
+// such as ICUTranslator and FluentTranslator. If SelectsVariants returns true, a blocktrans-tag with 'select' or
+// additional counters always translates its main body, and the 'case' variants are ignored, instead of being looked up
+// with their own contexts.
+type SelectTranslator interface {
+	SelectsVariants() bool
+}
+
+// selectContext returns the context used to translate a variant of a blocktrans-tag with 'select', e.g. 'gender=female'.
+// If the tag has a context of its own, it's used as a prefix, e.g. 'profile|gender=female'
+func selectContext(transCtx, name, value string) string {
+	if transCtx != "" {
+		return transCtx + "|" + name + "=" + value
 	}
+	return name + "=" + value
+}
 
-	transCtx := TransCtx{
-		Language: language,
-		Domain:   domain,
+// variant returns the context, the text and the plural text to translate for a value of 'select',
+// and the plural categories of the additional counters in blocktrans. The first matching variant is used,
+// or the main body if no variant matches. selected is false if no variant exists for the value of 'select'.
+//
+// Variants of a 'select' are translated with a context of their own, e.g. 'gender=female', and the category
+// of each additional counter is appended to the context, e.g. 'gender=female|folders=few', whether
+// a variant exists for the category or not.
+func (node *tagTransNode) variant(value string, selected bool, categories map[string]string) (msgctxt, text, plural string) {
+	msgctxt, text, plural = node.transCtx, node.transText, node.pluralText
+	if selected {
+		msgctxt = selectContext(msgctxt, node.selectName, value)
+	}
+	for _, name := range node.counters {
+		msgctxt = selectContext(msgctxt, name, categories[name])
 	}
 
+	for _, v := range node.variants {
+		if v.matches(value, selected, categories) {
+			return msgctxt, v.text, v.plural
+		}
+	}
+	return msgctxt, text, plural
+}
+
+// Execute translates and renders the node.
+// Parsed templates may be executed concurrently, so the node itself must never be modified here.
+func (node *tagTransNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) (transError *pongo2.Error) {
+	transCtx := getTransCtx(ctx)
+
 	// Do we need to evaluate the string to be translated?
+	transText := node.transText
 	if node.transEval != nil {
 		val, evalErr := node.transEval.Evaluate(ctx)
 		if evalErr != nil {
 			return evalErr
 		}
-		node.transText = val.String()
+		transText = val.String()
 	}
 
-	var content string
-	var err error
-	if node.pluralText != "" && node.countEval != nil {
-		countVal, evalErr := node.countEval.Evaluate(ctx)
+	// The translated string is rendered with a context of its own,
+	// so that the bound variables does not leak into the context of the parent
+	renderCtx := make(pongo2.Context, len(ctx.Public)+len(ctx.Private)+len(node.withEval))
+	renderCtx.Update(ctx.Public)
+	renderCtx.Update(ctx.Private)
+
+	if len(node.withEval) > 0 {
+		transCtx.Args = make(map[string]interface{}, len(node.withEval))
+	}
+	for key, eval := range node.withEval {
+		val, evalErr := eval.Evaluate(ctx)
 		if evalErr != nil {
 			return evalErr
 		}
+		transCtx.Args[key] = val.Interface()
 
-		if node.transCtx != "" {
-			content = node.translator.GetNC(transCtx, node.transText, node.pluralText, countVal.Integer(), node.transCtx)
-		} else {
-			content = node.translator.GetN(transCtx, node.transText, node.pluralText, countVal.Integer())
-		}
-	} else {
-		if node.transCtx != "" {
-			content = node.translator.GetC(transCtx, node.transText, node.transCtx)
-		} else {
-			content = node.translator.Get(transCtx, node.transText)
+		// The value is passed as is, so that values marked as safe are not escaped again.
+		// The 'safe'-filter doesn't mark the value itself, so it's checked separately
+		if eval.FilterApplied("safe") {
+			val = pongo2.AsSafeValue(val.Interface())
 		}
+		renderCtx[key] = val
 	}
 
-	if err != nil {
-		return ctx.Error(err.Error(), nil)
+	// Variants of a 'select' and additional counters are translated with a context of their own
+	msgctxt, pluralText := node.transCtx, node.pluralText
+	if st, ok := node.translator.(SelectTranslator); (node.selectName != "" || len(node.counters) > 0) && (!ok || !st.SelectsVariants()) {
+		var value string
+		var selected bool
+		if node.selectName != "" {
+			if v := transCtx.Args[node.selectName]; v != nil {
+				value = pongo2.AsValue(v).String()
+			}
+			for _, variant := range node.variants {
+				if variant.hasValue && variant.value == value {
+					selected = true
+					break
+				}
+			}
+		}
+
+		var categories map[string]string
+		if len(node.counters) > 0 {
+			tag, err := parseLanguage(transCtx.Language)
+			if err != nil {
+				tag = language.Und
+			}
+
+			categories = make(map[string]string, len(node.counters))
+			for _, name := range node.counters {
+				categories[name] = pluralCategory(tag, float64(pongo2.AsValue(transCtx.Args[name]).Integer()), false)
+			}
+		}
+		msgctxt, transText, pluralText = node.variant(value, selected, categories)
 	}
 
-	subCtx := pongo2.NewChildExecutionContext(ctx)
+	var content string
+	var err error
+	key := templateKey{
+		language: transCtx.Language,
+		domain:   transCtx.Domain,
+		context:  msgctxt,
+		id:       transText,
+
+		autoescape: ctx.Autoescape,
+	}
+	if pluralText != "" && node.countEval != nil {
+		key.plural = pluralText
 
-	for key, eval := range node.withEval {
-		val, evalErr := eval.Evaluate(ctx)
+		countVal, evalErr := node.countEval.Evaluate(ctx)
 		if evalErr != nil {
 			return evalErr
 		}
 
-		subCtx.Public[key] = val.Interface()
+		if msgctxt != "" {
+			content = node.translator.GetNC(transCtx, transText, pluralText, countVal.Integer(), msgctxt)
+		} else {
+			content = node.translator.GetN(transCtx, transText, pluralText, countVal.Integer())
+		}
+	} else {
+		if msgctxt != "" {
+			content = node.translator.GetC(transCtx, transText, msgctxt)
+		} else {
+			content = node.translator.Get(transCtx, transText)
+		}
 	}
 
-	content, err = pongo2.RenderTemplateString(content, subCtx.Public)
+	key.content = content
+	content, err = node.render(key, renderCtx)
 	if err != nil {
 		return ctx.Error(err.Error(), nil)
 	}
 
 	if node.asValue != "" {
-		ctx.Public[node.asValue] = content
+		// The translation has already been escaped, and must not be escaped again when it's used
+		ctx.Public[node.asValue] = pongo2.AsSafeValue(content)
 	} else {
 		_, err = writer.WriteString(content)
 		if err != nil {
@@ -97,6 +231,73 @@ func (node *tagTransNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 	return nil
 }
 
+// render renders a translated string as a template.
+// The compiled template is cached, if caching is enabled
+func (node *tagTransNode) render(key templateKey, ctx pongo2.Context) (string, error) {
+	var bound []string
+	key.untrusted, key.restricted = node.untrusted, node.restricted
+	if node.restricted {
+		bound = make([]string, 0, len(node.withEval))
+		for name := range node.withEval {
+			bound = append(bound, name)
+		}
+		sort.Strings(bound)
+		key.bound = strings.Join(bound, ",")
+	}
+
+	var tpl *pongo2.Template
+	if node.cache != nil {
+		tpl = node.cache.get(key)
+	}
+
+	if tpl == nil {
+		content := key.content
+		if node.restricted {
+			if err := ValidateTranslation(key.id, key.plural, content, bound...); err != nil {
+				return "", err
+			}
+		}
+
+		if node.untrusted && key.autoescape {
+			content = escapeText(content)
+		}
+
+		// Variables are escaped according to the autoescape setting of the parent template
+		if key.autoescape {
+			content = "{% autoescape on %}" + content + "{% endautoescape %}"
+		} else {
+			content = "{% autoescape off %}" + content + "{% endautoescape %}"
+		}
+
+		var err error
+		templateMutex.Lock()
+		tpl, err = pongo2.FromString(content)
+		templateMutex.Unlock()
+		if err != nil {
+			return "", err
+		}
+
+		if node.cache != nil {
+			node.cache.add(key, tpl)
+		}
+	}
+
+	return tpl.Execute(ctx)
+}
+
+// escapeText escapes HTML in the text of a translation, leaving template variables, tags and comments untouched
+func escapeText(content string) string {
+	var sb strings.Builder
+	pos := 0
+	for _, loc := range templateSyntax.FindAllStringIndex(content, -1) {
+		sb.WriteString(html.EscapeString(content[pos:loc[0]]))
+		sb.WriteString(content[loc[0]:loc[1]])
+		pos = loc[1]
+	}
+	sb.WriteString(html.EscapeString(content[pos:]))
+	return sb.String()
+}
+
 // NewTransTag creates a pongo2 tag for handling translations
 //
 // Usage:
@@ -107,13 +308,26 @@ func (node *tagTransNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 //	{% trans "This should be translated" %}
 //	{% trans "This should be translated, and has context" with ctx="example" %}
 //	{% trans "Save translation to var" as "myvar" %}{{myvar}}
-func NewTransTag(translator Translator) pongo2.TagParser {
+//
+//	// Expressions can be bound to variables with 'with', e.g. for translators that use them to format the translation
+//	{% trans "{count, plural, one {# file} other {# files}}" with count=files|length %}
+//
+// The templates compiled from the translated strings are cached, see TagOption for how the cache can be configured.
+func NewTransTag(translator Translator, options ...TagOption) pongo2.TagParser {
+	o := newTagOptions(options)
+	cache := newTemplateCache(translator, o.cacheSize)
+
 	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (tag pongo2.INodeTag, err *pongo2.Error) {
-		transNode := &tagTransNode{translator: translator}
+		transNode := &tagTransNode{
+			translator: translator,
+			cache:      cache,
+			untrusted:  o.untrusted,
+			restricted: o.restricted,
+		}
 
 		transNode.withEval = make(map[string]pongo2.IEvaluator)
 
-		if strToken := arguments.PeekType(pongo2.TokenString); strToken != nil {
+		if strToken := arguments.MatchType(pongo2.TokenString); strToken != nil {
 			transNode.transText = strToken.Val
 		} else if identifierToken := arguments.PeekType(pongo2.TokenIdentifier); identifierToken != nil {
 			transNode.transEval, err = arguments.ParseExpression()
@@ -123,26 +337,49 @@ func NewTransTag(translator Translator) pongo2.TagParser {
 		} else {
 			return nil, arguments.Error("Tag 'trans' requires at least one argument, which must be a string or identifier", nil)
 		}
-		arguments.Consume()
 
-		if t := arguments.Peek(pongo2.TokenKeyword, "as"); t != nil {
-			arguments.Consume()
-			asTag := arguments.Current()
-			if asTag == nil || asTag.Typ != pongo2.TokenIdentifier {
-				return nil, arguments.Error("Expected 'as' to be follow by an identifier", nil)
-			}
-			arguments.Consume()
-			transNode.asValue = asTag.Val
-		}
+		for arguments.Remaining() > 0 {
+			switch {
+			case arguments.Match(pongo2.TokenKeyword, "as") != nil:
+				if transNode.asValue != "" {
+					return nil, arguments.Error("'as' can only be specified once", nil)
+				}
 
-		if t := arguments.Peek(pongo2.TokenIdentifier, "context"); t != nil {
-			arguments.Consume()
-			transCtx := arguments.Current()
+				asTag := arguments.MatchType(pongo2.TokenIdentifier)
+				if asTag == nil {
+					return nil, arguments.Error("Expected 'as' to be follow by an identifier", nil)
+				}
+				transNode.asValue = asTag.Val
 
-			if transCtx == nil || transCtx.Typ != pongo2.TokenString {
-				return nil, arguments.Error("Expected 'context' to be followed by a string", nil)
+			case arguments.Match(pongo2.TokenIdentifier, "context") != nil:
+				if transNode.transCtx != "" {
+					return nil, arguments.Error("'context' can only be specified once", nil)
+				}
+
+				transCtx := arguments.MatchType(pongo2.TokenString)
+				if transCtx == nil {
+					return nil, arguments.Error("Expected 'context' to be followed by a string", nil)
+				}
+				transNode.transCtx = transCtx.Val
+
+			case arguments.Match(pongo2.TokenIdentifier, "with") != nil:
+				if err = parseBindings(arguments, transNode.withEval); err != nil {
+					return nil, err
+				}
+
+			default:
+				return nil, arguments.Error(fmt.Sprintf("Unknown argument '%s', expected 'as', 'context' or 'with'", arguments.Current().Val), nil)
 			}
-			transNode.transCtx = transCtx.Val
+		}
+
+		if o.extract != nil && transNode.transEval == nil && transNode.transText != "" {
+			o.extract(ExtractedMessage{
+				Context:  transNode.transCtx,
+				ID:       transNode.transText,
+				IDPlural: transNode.pluralText,
+				Filename: start.Filename,
+				Line:     start.Line,
+			})
 		}
 		return transNode, nil
 	}
@@ -1,7 +1,11 @@
 package trans
 
 import (
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/stretchr/testify/mock"
@@ -19,6 +23,10 @@ func TestTagTransNode_Execute(t *testing.T) {
 	testTrans.On("GetN", mock.Anything, "test-1", "test-2", 2).Return("ok-2", nil)
 	testTrans.On("GetNC", mock.Anything, "test-1", "test-2", 1, "myctx").Return("ok-1-ctx", nil)
 	testTrans.On("GetNC", mock.Anything, "test-1", "test-2", 2, "myctx").Return("ok-2-ctx", nil)
+	testTrans.On("Get", mock.Anything, "with {{ a }}").Return("with {{ a }}", nil)
+	testTrans.On("Get", mock.Anything, "with {{ a }} {{ b }}").Return("with {{ b }} {{ a }}", nil)
+	testTrans.On("GetN", mock.Anything, "{{ a }} {{ n }}", "{{ a }} {{ n }}s", 3).Return("{{ a }} {{ n }}s", nil)
+	testTrans.On("GetNC", mock.Anything, "{{ a }} {{ n }}", "{{ a }} {{ n }}s", 1, "myctx").Return("{{ a }} {{ n }} ctx", nil)
 	err := pongo2.RegisterTag("trans", NewTransTag(&testTrans))
 	if err != nil {
 		err = pongo2.ReplaceTag("trans", NewTransTag(&testTrans))
@@ -46,6 +54,20 @@ func TestTagTransNode_Execute(t *testing.T) {
 		{input: `{% trans "test" as othervar context "myctx" %}{{othervar}}`, expected: "ok-ctx"},
 		{input: `{% trans "test" as %}`, err: true},
 		{input: `{% trans "test" context blah %}`, err: true},
+		{input: `{% trans "with {{ a }}" with a=value %}`, expected: "with val"},
+		{input: `{% trans "with {{ a }} {{ b }}" with a=value b=obj.name %}`, expected: "with name val"},
+		{input: `{% trans "with {{ a }}" as res with a=value|upper %}[{{ res }}]`, expected: "[with VAL]"},
+		{input: `{% for i in "ab" %}{% trans "test" as myvar %}{% endfor %}{{ myvar }}`, expected: "ok"},
+		{input: `{% trans "test" with %}`, err: true},
+		{input: `{% trans msg with a=value %}`, expected: "with val"},
+		{input: `{% trans msgtest as myvar %}[{{ myvar }}]`, expected: "[ok]"},
+		{input: `{% trans msgtest context "myctx" %}`, expected: "ok-ctx"},
+		{input: `{% trans "with {{ a }}" with a=value as res %}[{{ res }}]`, expected: "[with val]"},
+		{input: `{% trans "test" with a=value context "myctx" %}`, expected: "ok-ctx"},
+		{input: `{% trans "test" bogus %}`, err: true},
+		{input: `{% trans msg bogus %}`, err: true},
+		{input: `{% trans "test" as a as b %}`, err: true},
+		{input: `{% trans "test" context "myctx" context "other" %}`, err: true},
 
 		{input: `{% blocktrans %}test{% endblocktrans %}`, expected: "ok"},
 		{input: `{% blocktrans %}test{% endblocktrans %} post`, expected: "ok post"},
@@ -61,7 +83,30 @@ func TestTagTransNode_Execute(t *testing.T) {
 		{input: `{% blocktrans context "myctx" asvar the_title %}test{% endblocktrans%}{{the_title}}`, expected: "ok-ctx"},
 		{input: `{% blocktrans count cnt=1 asvar the_title %}test-1{% plural %}test-2{% endblocktrans%}{{the_title}}`, expected: "ok-1"},
 		{input: `{% blocktrans count cnt=1 context "myctx" asvar the_title %}test-1{% plural %}test-2{% endblocktrans%}{{the_title}}`, expected: "ok-1-ctx"},
+		{input: `{% for i in "ab" %}{% blocktrans asvar the_title %}test{% endblocktrans %}{% endfor %}{{ the_title }}`, expected: "ok"},
 		{input: `{% blocktrans count cnt=2 context "myctx" asvar the_title %}test-1{% plural %}test-2{% endblocktrans%}{{the_title}}`, expected: "ok-2-ctx"},
+		{input: `{% blocktrans asvar the_title count cnt=2 context "myctx" %}test-1{% plural %}test-2{% endblocktrans%}{{the_title}}`, expected: "ok-2-ctx"},
+
+		{input: `{% blocktrans with a=value %}with {{ a }}{% endblocktrans %}`, expected: "with val"},
+		{input: `{% blocktrans with a=value|upper %}with {{ a }}{% endblocktrans %}`, expected: "with VAL"},
+		{input: `{% blocktrans with a=value b=obj.name %}with {{ a }} {{ b }}{% endblocktrans %}`, expected: "with name val"},
+		{input: `{% blocktrans with value as a %}with {{ a }}{% endblocktrans %}`, expected: "with val"},
+		{input: `{% blocktrans with value|upper as a and obj.name as b %}with {{ a }} {{ b }}{% endblocktrans %}`, expected: "with name VAL"},
+		{input: `{% blocktrans with a=value count n=3 %}{{ a }} {{ n }}{% plural %}{{ a }} {{ n }}s{% endblocktrans %}`, expected: "val 3s"},
+		{input: `{% blocktrans count n=3 with a=value %}{{ a }} {{ n }}{% plural %}{{ a }} {{ n }}s{% endblocktrans %}`, expected: "val 3s"},
+		{input: `{% blocktrans count 3 as n with value as a %}{{ a }} {{ n }}{% plural %}{{ a }} {{ n }}s{% endblocktrans %}`, expected: "val 3s"},
+		{input: `{% blocktrans context "myctx" count n=1 with a=value asvar res %}{{ a }} {{ n }}{% plural %}{{ a }} {{ n }}s{% endblocktrans %}[{{ res }}]`, expected: "[val 1 ctx]"},
+		{input: `{% blocktrans with %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans with a %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans with a= %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans with value as %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans with a=value a=value %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans with a=value count a=1 %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 count n=2 %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans context "a" context "b" %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans context %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans asvar %}test{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans unknown %}test{% endblocktrans %}`, err: true},
 	}
 
 	for k, tst := range tests {
@@ -71,7 +116,12 @@ func TestTagTransNode_Execute(t *testing.T) {
 			continue
 		}
 		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
-		result, err := tmpl.Execute(pongo2.Context{})
+		result, err := tmpl.Execute(pongo2.Context{
+			"value":   "val",
+			"obj":     map[string]string{"name": "name"},
+			"msg":     "with {{ a }}",
+			"msgtest": "test",
+		})
 		require.Nilf(t, err, "test: %s input: %s", k, tst.input)
 		require.Equalf(t, tst.expected, result, "test: %s input: %s", k, tst.input)
 	}
@@ -127,3 +177,470 @@ func TestTagTransNode_ContextAccess(t *testing.T) {
 		require.Equalf(t, tst.expected, result, "test: %s input: %s", k, tst.input)
 	}
 }
+
+// Check that variables bound in the tags does not leak into the context of the template,
+// and that variables from the template (e.g. loop variables) are available in the translation
+func TestTagTransNode_Scope(t *testing.T) {
+	testTrans := TestTranslator{}
+	err := pongo2.RegisterTag("trans", NewTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	tmpl, err := pongo2.FromString(`{% blocktrans with a=value %}{{ a }}{% endblocktrans %}[{{ a }}]` +
+		`{% for v in list %}{% blocktrans %}{{ v }}{% endblocktrans %}{% endfor %}` +
+		`{% trans "test" as myvar %}{{ myvar }}`)
+	require.Nil(t, err)
+
+	ctx := pongo2.Context{"value": "val", "list": []string{"x", "y"}}
+	result, err := tmpl.Execute(ctx)
+	require.Nil(t, err)
+	require.Equal(t, "::val[]::x::y::test", result)
+
+	// The context passed to the template should not have been modified
+	require.Equal(t, pongo2.Context{"value": "val", "list": []string{"x", "y"}}, ctx)
+}
+
+// Check that a parsed template can be executed concurrently.
+// This test is most useful when run with the race detector enabled ('go test -race')
+func TestTagTransNode_Concurrent(t *testing.T) {
+	testTrans := TestTranslator{}
+	err := pongo2.RegisterTag("trans", NewTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", NewTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	// The variable set with 'as' must not be written by the tag, and must not leak between executions
+	tmpl, err := pongo2.FromString(`{% trans msg %}|[{{ myvar }}]{% trans msg as myvar %}[{{ myvar }}]|` +
+		`{% blocktrans with v=value %}{{ v }}{% endblocktrans %}|` +
+		`{% blocktrans count n=cnt %}{{ n }} item{% plural %}{{ n }} items{% endblocktrans %}`)
+	require.Nil(t, err)
+
+	const goroutines = 50
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				language := fmt.Sprintf("lang%d", g)
+				msg := fmt.Sprintf("msg-%d-%d", g, i)
+				value := fmt.Sprintf("value-%d-%d", g, i)
+
+				result, err := tmpl.Execute(pongo2.Context{
+					"_language": language,
+					"msg":       msg,
+					"value":     value,
+					"cnt":       i,
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				expected := fmt.Sprintf(":%[1]s:%[2]s|[][:%[1]s:%[2]s]|:%[1]s:%[3]s|:%[1]s:%[4]d item:%[4]d items:%[4]d",
+					language, msg, value, i)
+				if result != expected {
+					errs <- fmt.Errorf("expected %q, got %q", expected, result)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.Nil(t, err)
+	}
+}
+
+// registerTransTag registers the 'trans'-tag, and returns a pointer to all nodes created by it
+func registerTransTag(t testing.TB, parser pongo2.TagParser) *[]*tagTransNode {
+	var nodes []*tagTransNode
+	fn := func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+		node, err := parser(doc, start, arguments)
+		if node != nil {
+			nodes = append(nodes, node.(*tagTransNode))
+		}
+		return node, err
+	}
+
+	err := pongo2.RegisterTag("trans", fn)
+	if err != nil {
+		err = pongo2.ReplaceTag("trans", fn)
+	}
+	require.Nil(t, err)
+	return &nodes
+}
+
+func TestTagTransNode_CacheOptions(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": testCatalog("Hej {{ secret }}<b>!</b>"),
+	}
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+
+	// The tags share the cache of the translator, but must not use templates compiled with other options
+	tags := map[string]pongo2.TagParser{
+		"trans":  NewTransTag(tt),
+		"rtrans": NewTransTag(tt, WithRestrictedInterpolation()),
+		"utrans": NewTransTag(tt, WithUntrustedTranslations()),
+	}
+	for name, tag := range tags {
+		err = pongo2.RegisterTag(name, tag)
+		if err != nil {
+			err = pongo2.ReplaceTag(name, tag)
+		}
+		require.Nil(t, err)
+	}
+
+	ctx := pongo2.Context{"_language": "sv_SE", "secret": "s3cret"}
+	tmpl, err := pongo2.FromString(`{% trans "Hello world!" %}`)
+	require.Nil(t, err)
+	result, err := tmpl.Execute(ctx)
+	require.Nil(t, err)
+	require.Equal(t, "Hej s3cret<b>!</b>", result)
+
+	for _, input := range []string{`{% rtrans "Hello world!" %}`, `{% utrans "Hello world!" %}`} {
+		tmpl, err = pongo2.FromString(input)
+		require.Nil(t, err)
+		result, err = tmpl.Execute(ctx)
+		require.NotNilf(t, err, "input: %s", input)
+		require.NotContainsf(t, result, "s3cret", "input: %s", input)
+	}
+}
+
+func TestTagTransNode_Cache(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": testCatalog("Hej {{ name }}!"),
+	}
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+
+	nodes := registerTransTag(t, NewTransTag(tt))
+	tmpl, err := pongo2.FromString(`{% trans "Hello world!" %}`)
+	require.Nil(t, err)
+	require.Len(t, *nodes, 1)
+	cache := (*nodes)[0].cache
+	require.NotNil(t, cache)
+
+	execute := func(language string) string {
+		result, err := tmpl.Execute(pongo2.Context{"_language": language, "name": "Bob"})
+		require.Nil(t, err)
+		return result
+	}
+
+	// Each translation should only be compiled once
+	require.Equal(t, "Hej Bob!", execute("sv_SE"))
+	require.Equal(t, "Hej Bob!", execute("sv_SE"))
+	require.Equal(t, 1, cache.len())
+	require.Equal(t, "Hello world!", execute("en_GB"))
+	require.Equal(t, 2, cache.len())
+
+	// The cache should be cleared when the catalogs are reloaded
+	localeFS["locales/sv_SE/default.po"] = testCatalog("Hallå {{ name }}!")
+	require.Nil(t, tt.Reload(nil))
+	require.Equal(t, 0, cache.len())
+	require.Equal(t, "Hallå Bob!", execute("sv_SE"))
+
+	// The size of the cache can be limited
+	nodes = registerTransTag(t, NewTransTag(tt, WithTemplateCacheSize(1)))
+	tmpl, err = pongo2.FromString(`{% trans "Hello world!" %}`)
+	require.Nil(t, err)
+	require.Equal(t, "Hallå Bob!", execute("sv_SE"))
+	require.Equal(t, "Hello world!", execute("en_GB"))
+	require.Equal(t, 1, (*nodes)[0].cache.len())
+
+	// The tags share the cache, so creating them again doesn't register more functions with OnReload
+	for i := 0; i < 3; i++ {
+		nodes = registerTransTag(t, NewTransTag(tt))
+		_, err = pongo2.FromString(`{% trans "Hello world!" %}`)
+		require.Nil(t, err)
+		require.True(t, cache == (*nodes)[0].cache)
+		NewBlockTransTag(tt)
+	}
+	require.Len(t, tt.onReload, 2)
+
+	// And disabled altogether
+	nodes = registerTransTag(t, NewTransTag(tt, WithoutTemplateCache()))
+	tmpl, err = pongo2.FromString(`{% trans "Hello world!" %}`)
+	require.Nil(t, err)
+	require.Nil(t, (*nodes)[0].cache)
+	require.Equal(t, "Hallå Bob!", execute("sv_SE"))
+}
+
+func benchmarkTagTransNode(b *testing.B, options ...TagOption) {
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales")
+	require.Nil(b, err)
+	registerTransTag(b, NewTransTag(tt, options...))
+
+	err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(tt, options...))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(tt, options...))
+	}
+	require.Nil(b, err)
+
+	tmpl, err := pongo2.FromString(`{% trans "Hello world!" %}
+{% for i in items %}{% blocktrans count n=i with name=user %}{{ name }} has {{ n }} file{% plural %}{{ name }} has {{ n }} files{% endblocktrans %}{% endfor %}`)
+	require.Nil(b, err)
+
+	ctx := pongo2.Context{"_language": "sv_SE", "user": "Bob", "items": []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tmpl.Execute(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTagTransNode_Cached(b *testing.B) {
+	benchmarkTagTransNode(b)
+}
+
+func BenchmarkTagTransNode_Uncached(b *testing.B) {
+	benchmarkTagTransNode(b, WithoutTemplateCache())
+}
+
+func TestTagTransNode_Autoescape(t *testing.T) {
+	testTrans := TestTranslator{}
+	untrustedTrans := MockTranslator{}
+	untrustedTrans.On("Get", mock.Anything, "markup").Return(`<b>{{ name }}</b> & "{{ name }}"{# <i> #}`, nil)
+	untrustedTrans.On("Get", mock.Anything, "safe variable").Return(`{{ name|safe }}`, nil)
+	untrustedTrans.On("Get", mock.Anything, "safe string").Return(`{{ "<script>x</script>"|safe }}`, nil)
+	untrustedTrans.On("Get", mock.Anything, "autoescape").Return(`{% autoescape off %}<script>x</script>{% endautoescape %}`, nil)
+	untrustedTrans.On("Get", mock.Anything, "breakout").Return(`{% endautoescape %}<script>x</script>`, nil)
+	untrustedTrans.On("Get", mock.Anything, "unbalanced").Return(`{{ name <script>x</script>`, nil)
+
+	type T struct {
+		input     string
+		expected  string
+		untrusted bool
+		err       bool
+	}
+
+	const script = "<script>alert('x')</script>"
+	const escapedScript = "&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;"
+
+	tests := []T{
+		// Bound variables should be escaped
+		{input: `{% blocktrans with a=value %}{{ a }}{% endblocktrans %}`, expected: "domain:language:" + escapedScript},
+		{input: `{% blocktrans %}{{ value }}{% endblocktrans %}`, expected: "domain:language:" + escapedScript},
+		{input: `{% blocktrans with a=value count n=2 %}{{ a }}{% plural %}{{ a }} {{ n }}{% endblocktrans %}`, expected: "domain:language:" + escapedScript + ":" + escapedScript + " 2:2"},
+		// Unless they have been marked as safe
+		{input: `{% blocktrans with a=value|safe %}{{ a }}{% endblocktrans %}`, expected: "domain:language:" + script},
+		{input: `{% blocktrans with a=name|safe b=value %}{{ a }} {{ b }}{% endblocktrans %}`, expected: "domain:language:<b>bold</b> " + escapedScript},
+		// Or autoescaping is disabled
+		{input: `{% autoescape off %}{% blocktrans with a=value %}{{ a }}{% endblocktrans %}{% endautoescape %}`, expected: "domain:language:" + script},
+		// Stored translations should not be escaped twice
+		{input: `{% blocktrans with a=name asvar v %}{{ a }}{% endblocktrans %}{{ v }}`, expected: "domain:language:&lt;b&gt;bold&lt;/b&gt;"},
+		{input: `{% trans "<b>" as v %}{{ v }}`, expected: "domain:language:<b>"},
+		// Translations are trusted by default
+		{input: `{% blocktrans %}<b>{{ name }}</b>{% endblocktrans %}`, expected: "domain:language:<b>&lt;b&gt;bold&lt;/b&gt;</b>"},
+		// Unless configured otherwise
+		{input: `{% trans "markup" with name=name %}`, expected: "&lt;b&gt;&lt;b&gt;bold&lt;/b&gt;&lt;/b&gt; &amp; &#34;&lt;b&gt;bold&lt;/b&gt;&#34;", untrusted: true},
+		{input: `{% trans "markup" with name=name|safe %}`, expected: "&lt;b&gt;<b>bold</b>&lt;/b&gt; &amp; &#34;<b>bold</b>&#34;", untrusted: true},
+		{input: `{% autoescape off %}{% trans "markup" with name=name %}{% endautoescape %}`, expected: `<b><b>bold</b></b> & "<b>bold</b>"`, untrusted: true},
+		// Untrusted translations cannot use tags or filters to bypass escaping
+		{input: `{% trans "safe variable" with name=name %}`, untrusted: true, err: true},
+		{input: `{% trans "safe string" %}`, untrusted: true, err: true},
+		{input: `{% trans "autoescape" %}`, untrusted: true, err: true},
+		{input: `{% trans "breakout" %}`, untrusted: true, err: true},
+		{input: `{% trans "unbalanced" with name=name %}`, untrusted: true, err: true},
+	}
+
+	for k, tst := range tests {
+		var translator Translator = &testTrans
+		var options []TagOption
+		if tst.untrusted {
+			translator = &untrustedTrans
+			options = append(options, WithUntrustedTranslations())
+		}
+
+		err := pongo2.RegisterTag("trans", NewTransTag(translator, options...))
+		if err != nil {
+			err = pongo2.ReplaceTag("trans", NewTransTag(translator, options...))
+		}
+		require.Nil(t, err)
+
+		err = pongo2.RegisterTag("blocktrans", NewBlockTransTag(translator, options...))
+		if err != nil {
+			err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(translator, options...))
+		}
+		require.Nil(t, err)
+
+		tmpl, err := pongo2.FromString(tst.input)
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		result, err := tmpl.Execute(pongo2.Context{
+			"_domain":   "domain",
+			"_language": "language",
+			"value":     script,
+			"name":      "<b>bold</b>",
+		})
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			require.NotContainsf(t, result, "<script>", "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+	}
+}
+
+func TestTagTransNode_Select(t *testing.T) {
+	testTrans := TestTranslator{}
+	err := pongo2.RegisterTag("blocktrans", NewBlockTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	type T struct {
+		input    string
+		expected string
+		err      bool
+	}
+
+	const profile = `{% blocktrans select gender=user.gender with name=user.name %}{{ name }} updated their profile` +
+		`{% case "female" %}{{ name }} updated her profile{% case "male" %}{{ name }} updated his profile{% endblocktrans %}`
+	const files = `{% blocktrans select gender=user.gender count n=2 context "files" %}{{ gender }} {{ n }} file{% plural %}{{ gender }} {{ n }} files` +
+		`{% case "female" %}her {{ n }} file{% plural %}her {{ n }} files{% case "male" %}his file{% endblocktrans %}`
+
+	tests := []T{
+		{input: profile, expected: "domain:language:gender=female:Eve updated her profile"},
+		{input: strings.Replace(profile, "user.gender", `"male"`, 1), expected: "domain:language:gender=male:Eve updated his profile"},
+		{input: strings.Replace(profile, "user.gender", `"other"`, 1), expected: "domain:language:Eve updated their profile"},
+		{input: strings.Replace(profile, "user.gender", "missing", 1), expected: "domain:language:Eve updated their profile"},
+		{input: files, expected: "domain:language:files|gender=female:her 2 file:her 2 files:2"},
+		{input: strings.Replace(files, "user.gender", `"male"`, 1), expected: "domain:language:files|gender=male:his file"},
+		{input: strings.Replace(files, "user.gender", `"x"`, 1), expected: "domain:language:files:x 2 file:x 2 files:2"},
+		{input: `{% blocktrans select gender=user.gender asvar res %}a{% case "female" %}b{% endblocktrans %}[{{ res }}]`, expected: "[domain:language:gender=female:b]"},
+		{input: `{% blocktrans select gender=user.gender select g=1 %}a{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans select %}a{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans %}a{% case "female" %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans select g=1 %}a{% case female %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans select g=1 %}a{% case "x" "y" %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans select g=1 %}a{% case "x" %}b{% case "x" %}c{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans select g=1 %}a{% case "x" %}b`, err: true},
+	}
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		result, err := tmpl.Execute(pongo2.Context{
+			"_domain":   "domain",
+			"_language": "language",
+			"user":      map[string]string{"name": "Eve", "gender": "female"},
+		})
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+	}
+
+	// Translators that select variants themselves always get the main body, with the value in the arguments
+	it := NewICUTranslator(&testTrans)
+	err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(it))
+	require.Nil(t, err)
+
+	tmpl, err := pongo2.FromString(`{% blocktrans select gender=user.gender %}{gender, select, female {her} other {their}}{% case "female" %}her{% endblocktrans %}`)
+	require.Nil(t, err)
+	result, err := tmpl.Execute(pongo2.Context{"user": map[string]string{"gender": "female"}})
+	require.Nil(t, err)
+	require.Equal(t, "::her", result)
+}
+
+func TestTagTransNode_Counters(t *testing.T) {
+	testTrans := TestTranslator{}
+	err := pongo2.RegisterTag("blocktrans", NewBlockTransTag(&testTrans))
+	if err != nil {
+		err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(&testTrans))
+	}
+	require.Nil(t, err)
+
+	type T struct {
+		input    string
+		language string
+		folders  int
+		expected string
+		err      bool
+	}
+
+	const files = `{% blocktrans count n=2 count m=folders %}{{ n }} file in {{ m }} folders{% plural %}{{ n }} files in {{ m }} folders` +
+		`{% case m "one" %}{{ n }} file in {{ m }} folder{% plural %}{{ n }} files in {{ m }} folder{% endblocktrans %}`
+	const profile = `{% blocktrans select gender=g count n=1 count m=folders %}their {{ m }}{% case "female" m "one" %}her only {{ m }}` +
+		`{% case "female" %}her {{ m }}{% case m "one" %}their only {{ m }}{% endblocktrans %}`
+
+	tests := []T{
+		{input: files, language: "en", folders: 1, expected: "domain:en:m=one:2 file in 1 folder:2 files in 1 folder:2"},
+		{input: files, language: "en", folders: 3, expected: "domain:en:m=other:2 file in 3 folders:2 files in 3 folders:2"},
+		{input: files, language: "ru", folders: 5, expected: "domain:ru:m=many:2 file in 5 folders:2 files in 5 folders:2"},
+		{input: files, language: "ru", folders: 22, expected: "domain:ru:m=few:2 file in 22 folders:2 files in 22 folders:2"},
+		{input: files, language: "ru", folders: 21, expected: "domain:ru:m=one:2 file in 21 folder:2 files in 21 folder:2"},
+		{input: files, language: "invalid", folders: 1, expected: "domain:invalid:m=other:2 file in 1 folders:2 files in 1 folders:2"},
+		{input: `{% blocktrans count n=1 count m=folders count k=2 context "c" %}{{ n }} {{ m }} {{ k }}{% endblocktrans %}`,
+			language: "en", folders: 1, expected: "domain:en:c|m=one|k=other:1 1 2"},
+		{input: profile, language: "en", folders: 1, expected: "domain:en:gender=female|m=one:her only 1"},
+		{input: profile, language: "en", folders: 2, expected: "domain:en:gender=female|m=other:her 2"},
+		{input: strings.Replace(profile, "gender=g", `gender="x"`, 1), language: "en", folders: 1, expected: "domain:en:m=one:their only 1"},
+		{input: strings.Replace(profile, "gender=g", `gender="x"`, 1), language: "en", folders: 2, expected: "domain:en:m=other:their 2"},
+		{input: `{% blocktrans count n=1 count m=2 %}a{% case m %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 count m=2 %}a{% case m "uno" %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 count m=2 %}a{% case n "one" %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 count m=2 %}a{% case m "one" m "few" %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 count m=2 %}a{% case m "one" %}b{% case m "one" %}c{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 count m=2 %}a{% case "female" %}b{% endblocktrans %}`, err: true},
+		{input: `{% blocktrans count n=1 %}a{% case n "one" %}b{% endblocktrans %}`, err: true},
+	}
+
+	for k, tst := range tests {
+		tmpl, err := pongo2.FromString(tst.input)
+		if tst.err {
+			require.NotNilf(t, err, "test: %d, input: %s", k, tst.input)
+			continue
+		}
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+
+		result, err := tmpl.Execute(pongo2.Context{
+			"_domain":   "domain",
+			"_language": tst.language,
+			"folders":   tst.folders,
+			"g":         "female",
+		})
+		require.Nilf(t, err, "test: %d, input: %s", k, tst.input)
+		require.Equalf(t, tst.expected, result, "test: %d, input: %s", k, tst.input)
+	}
+
+	// Translators that select variants themselves get all counters in the arguments
+	it := NewICUTranslator(&testTrans)
+	err = pongo2.ReplaceTag("blocktrans", NewBlockTransTag(it))
+	require.Nil(t, err)
+
+	tmpl, err := pongo2.FromString(`{% blocktrans count n=2 count m=folders %}{n} {n, plural, one {file} other {files}} in ` +
+		`{m} {m, plural, one {folder} other {folders}}{% case m "one" %}ignored{% endblocktrans %}`)
+	require.Nil(t, err)
+	result, err := tmpl.Execute(pongo2.Context{"_language": "en", "folders": 1})
+	require.Nil(t, err)
+	require.Equal(t, ":en:2 files in 1 folder", result)
+}
@@ -1,59 +1,232 @@
 package trans
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
+	"log"
 	"path"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/leonelquinteros/gotext"
+	"github.com/yzzyx/pongo-trans/catalog"
 )
 
-// TemplateTranslator wraps gotext in an interface compatible with tagtrans
+// TemplateTranslator translates strings with gettext catalogs, in an interface compatible with tagtrans
 type TemplateTranslator struct {
-	locales map[string]*gotext.Locale
+	localeFS   fs.FS
+	localePath string
+
+	fallbacks       map[string][]string
+	defaultFallback []string
+	missingHandler  MissingTranslationHandler
+	extensions      map[string]bool
+
+	// mu protects the fields below, which are replaced when the catalogs are reloaded
+	mu        sync.RWMutex
+	locales   map[string]*locale
+	languages []string
+	signature string
+	onReload  []func()
 }
 
-// Get translates a string using gotext
-func (t *TemplateTranslator) Get(ctx TransCtx, str string, values ...interface{}) string {
-	// Always return empty string as translation for empty string,
-	// otherwise the gettext header will be returned instead
-	if str == "" {
-		return ""
+// TranslatorOption configures optional behaviour of a TemplateTranslator
+type TranslatorOption func(t *TemplateTranslator)
+
+// WithFallback sets the languages to use, in order, when a message has not been translated to language.
+// Fallbacks are applied per message, so a partially translated catalog falls back to the
+// next language in the chain for the missing messages only:
+//
+//	trans.NewTemplateTranslator(localeFS, "locales",
+//		trans.WithFallback("nb_NO", "no", "nn", "en"),
+//		trans.WithFallback("pt_BR", "pt_PT"))
+//
+// A chain specified for a general language (e.g. 'pt') is also used for its regional variants (e.g. 'pt_BR'),
+// unless the regional variant has a chain of its own.
+func WithFallback(language string, fallbacks ...string) TranslatorOption {
+	return func(t *TemplateTranslator) {
+		t.fallbacks[language] = fallbacks
+	}
+}
+
+// WithDefaultFallback sets the languages to use, in order, for all languages without a chain set with WithFallback
+func WithDefaultFallback(fallbacks ...string) TranslatorOption {
+	return func(t *TemplateTranslator) {
+		t.defaultFallback = fallbacks
 	}
+}
 
-	l, ok := t.locales[ctx.Language]
+// WithCatalogExtensions sets the extensions of the files in the locale directory that are read as catalogs.
+// Defaults to '.po' and '.mo', and '.json', '.xlf', '.xliff' and '.ftl' are supported as well:
+//
+//	trans.NewTemplateTranslator(localeFS, "locales", trans.WithCatalogExtensions(".po", ".mo", ".json"))
+//
+// Files with other extensions are ignored, so e.g. unrelated .json-files don't have to be moved out of the
+// locale directory unless JSON catalogs are used. Fluent resources (.ftl) are normally read by NewFluentTranslator.
+func WithCatalogExtensions(extensions ...string) TranslatorOption {
+	return func(t *TemplateTranslator) {
+		t.extensions = map[string]bool{}
+		for _, ext := range extensions {
+			t.extensions[ext] = true
+		}
+	}
+}
+
+// locale contains all domains loaded for a language
+type locale struct {
+	domains map[string]*catalog.Catalog
+	fluent  map[string]bool // The domains read from Fluent resources
+}
+
+// Languages returns all languages found in the locale directory, sorted by language code
+func (t *TemplateTranslator) Languages() []LanguageInfo {
+	t.mu.RLock()
+	codes := t.languages
+	t.mu.RUnlock()
+
+	languages := make([]LanguageInfo, 0, len(codes))
+	for _, code := range codes {
+		languages = append(languages, t.LanguageInfo(code))
+	}
+	return languages
+}
+
+// TranslationStats contains the number of messages in a domain, by translation status
+type TranslationStats struct {
+	Language string `json:"language"`
+	Domain   string `json:"domain"`
+	catalog.Stats
+	Percent float64 `json:"percent"` // Percentage of the messages that has been translated
+}
+
+// Stats returns the translation status of each domain in each language, sorted by language and domain.
+// Terms and attributes in Fluent resources are not counted, only the messages
+func (t *TemplateTranslator) Stats() []TranslationStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var stats []TranslationStats
+	for _, language := range t.languages {
+		l := t.locales[language]
+
+		var domains []string
+		for domain := range l.domains {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+
+		for _, domain := range domains {
+			var s catalog.Stats
+			if l.fluent[domain] {
+				s = fluentStats(l.domains[domain])
+			} else {
+				s = l.domains[domain].Stats()
+			}
+			stats = append(stats, TranslationStats{Language: language, Domain: domain, Stats: s, Percent: s.Percent()})
+		}
+	}
+	return stats
+}
+
+// LanguageInfo returns information about a language.
+// The language does not have to be available in the translator.
+func (t *TemplateTranslator) LanguageInfo(code string) LanguageInfo {
+	info := newLanguageInfo(code)
+	if l := t.getLocale(code); l != nil {
+		info.NPlurals = l.nplurals()
+	}
+	return info
+}
+
+// getLocale returns the locale for a language, or nil if the language isn't available
+func (t *TemplateTranslator) getLocale(language string) *locale {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.locales[language]
+}
+
+// fallbackChain returns the languages to use when translating to language, in order
+func (t *TemplateTranslator) fallbackChain(language string) []string {
+	fallbacks, ok := t.fallbacks[language]
 	if !ok {
-		return str
+		if idx := strings.Index(language, "_"); idx > 0 {
+			fallbacks, ok = t.fallbacks[language[:idx]]
+		}
+	}
+	if !ok {
+		fallbacks = t.defaultFallback
 	}
 
+	chain := make([]string, 0, len(fallbacks)+1)
+	chain = append(chain, language)
+	for _, fallback := range fallbacks {
+		if fallback != language {
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+// lookup calls fn with the catalog for each language in the fallback chain of ctx.Language,
+// until a translation is found
+func (t *TemplateTranslator) lookup(ctx TransCtx, fn func(cat *catalog.Catalog) (string, bool)) (string, bool) {
 	dom := ctx.Domain
 	if dom == "" {
 		dom = "default"
 	}
-	return l.GetD(dom, str, values...)
+
+	for _, language := range t.fallbackChain(ctx.Language) {
+		l := t.getLocale(language)
+		if l == nil || l.domains[dom] == nil {
+			continue
+		}
+
+		if tr, ok := fn(l.domains[dom]); ok {
+			return tr, true
+		}
+	}
+	return "", false
 }
 
-// GetC translates a string using gotext, with a specific translation context
-func (t *TemplateTranslator) GetC(ctx TransCtx, str string, transctx string, values ...interface{}) string {
+// Get translates a string
+func (t *TemplateTranslator) Get(ctx TransCtx, str string, values ...interface{}) string {
 	// Always return empty string as translation for empty string,
 	// otherwise the gettext header will be returned instead
 	if str == "" {
 		return ""
 	}
 
-	l, ok := t.locales[ctx.Language]
-	if !ok {
-		return str
+	tr, ok := t.lookup(ctx, func(cat *catalog.Catalog) (string, bool) {
+		return cat.Translation("", str)
+	})
+	if ok {
+		return printf(tr, values...)
 	}
+	t.missing(ctx, "", str, "")
+	return printf(str, values...)
+}
 
-	dom := ctx.Domain
-	if dom == "" {
-		dom = "default"
+// GetC translates a string, with a specific translation context
+func (t *TemplateTranslator) GetC(ctx TransCtx, str string, transctx string, values ...interface{}) string {
+	// Always return empty string as translation for empty string,
+	// otherwise the gettext header will be returned instead
+	if str == "" {
+		return ""
+	}
+
+	tr, ok := t.lookup(ctx, func(cat *catalog.Catalog) (string, bool) {
+		return cat.Translation(transctx, str)
+	})
+	if ok {
+		return printf(tr, values...)
 	}
-	return l.GetDC(dom, str, transctx, values...)
+	t.missing(ctx, transctx, str, "")
+	return printf(str, values...)
 }
 
-// GetN translates a string using gotext, with support for plurals
+// GetN translates a string, with support for plurals
 func (t *TemplateTranslator) GetN(ctx TransCtx, str string, plural string, count int, values ...interface{}) string {
 	// Always return empty string as translation for empty string,
 	// otherwise the gettext header will be returned instead
@@ -61,19 +234,21 @@ func (t *TemplateTranslator) GetN(ctx TransCtx, str string, plural string, count
 		return ""
 	}
 
-	l, ok := t.locales[ctx.Language]
-	if !ok {
-		return str
+	tr, ok := t.lookup(ctx, func(cat *catalog.Catalog) (string, bool) {
+		return cat.PluralTranslation("", str, count)
+	})
+	if ok {
+		return printf(tr, values...)
 	}
+	t.missing(ctx, "", str, plural)
 
-	dom := ctx.Domain
-	if dom == "" {
-		dom = "default"
+	if count == 1 {
+		return printf(str, values...)
 	}
-	return l.GetND(dom, str, plural, count, values...)
+	return printf(plural, values...)
 }
 
-// GetNC translates a string using gotext, with a specific translation context, with support for plurals
+// GetNC translates a string, with a specific translation context, with support for plurals
 func (t *TemplateTranslator) GetNC(ctx TransCtx, str string, plural string, count int, transctx string, values ...interface{}) string {
 	// Always return empty string as translation for empty string,
 	// otherwise the gettext header will be returned instead
@@ -81,96 +256,298 @@ func (t *TemplateTranslator) GetNC(ctx TransCtx, str string, plural string, coun
 		return ""
 	}
 
-	l, ok := t.locales[ctx.Language]
-	if !ok {
-		return str
+	tr, ok := t.lookup(ctx, func(cat *catalog.Catalog) (string, bool) {
+		return cat.PluralTranslation(transctx, str, count)
+	})
+	if ok {
+		return printf(tr, values...)
 	}
+	t.missing(ctx, transctx, str, plural)
 
-	dom := ctx.Domain
-	if dom == "" {
-		dom = "default"
+	if count == 1 {
+		return printf(str, values...)
 	}
-	return l.GetNDC(dom, str, plural, count, transctx, values...)
+	return printf(plural, values...)
 }
 
-// NewTemplateTranslator creates a new translator, initialized with available locales
-func NewTemplateTranslator(localeFS fs.FS, localePath string) (*TemplateTranslator, error) {
-	localeDirs, err := fs.ReadDir(localeFS, localePath)
+// missing reports a message that could not be translated to the missing translation handler, if any.
+// Messages translated to the pseudo-language of a PseudoTranslator are not reported, since they would be reported
+// for every message, and the message is already reported for the real languages it's missing in
+func (t *TemplateTranslator) missing(ctx TransCtx, transctx, str, plural string) {
+	if t.missingHandler == nil || ctx.pseudo {
+		return
+	}
+
+	t.missingHandler(MissingTranslation{
+		Language: ctx.Language,
+		Domain:   ctx.Domain,
+		Context:  transctx,
+		ID:       str,
+		IDPlural: plural,
+	})
+}
+
+// printf only formats the string if any values are given
+func printf(str string, values ...interface{}) string {
+	if len(values) > 0 {
+		return fmt.Sprintf(str, values...)
+	}
+	return str
+}
+
+// Reload reads all catalogs in the locale directory again.
+//
+// If a catalog cannot be read or parsed, the error is passed to onError (if it's not nil),
+// and the previously loaded version of that catalog is kept.
+// An error is only returned if the locale directory itself cannot be read.
+func (t *TemplateTranslator) Reload(onError func(error)) error {
+	signature, err := t.readSignature()
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	previous := t.locales
+	t.mu.RUnlock()
+
+	locales, languages, err := loadLocales(t.localeFS, t.localePath, t.extensions, previous, onError)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.locales = locales
+	t.languages = languages
+	t.signature = signature
+	onReload := t.onReload
+	t.mu.Unlock()
+
+	for _, fn := range onReload {
+		fn()
+	}
+	return nil
+}
+
+// OnReload registers a function that is called every time the catalogs have been reloaded
+func (t *TemplateTranslator) OnReload(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onReload = append(t.onReload, fn)
+}
+
+// Watch checks the locale directory for changes every interval, and reloads the catalogs when
+// a file has been added, removed or modified. Since polling is used, this works with any fs.FS.
+//
+// Watch blocks until ctx is cancelled, and should normally be started in a separate goroutine:
+//
+//	go tr.Watch(ctx, 2*time.Second, func(err error) { log.Printf("Could not reload translations: %v", err) })
+//
+// Errors are passed to onError (if it's not nil). Catalogs that cannot be parsed are not replaced.
+func (t *TemplateTranslator) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		signature, err := t.readSignature()
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+
+		t.mu.RLock()
+		changed := signature != t.signature
+		t.mu.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err = t.Reload(onError); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// readSignature returns a string describing the name, size and modification time
+// of all files in the locale directory, used to detect changes
+func (t *TemplateTranslator) readSignature() (string, error) {
+	var sb strings.Builder
+	localeDirs, err := fs.ReadDir(t.localeFS, t.localePath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, dirEntry := range localeDirs {
+		if !dirEntry.IsDir() {
+			continue
+		}
+
+		lp := path.Join(t.localePath, dirEntry.Name())
+		domainFiles, err := fs.ReadDir(t.localeFS, lp)
+		if err != nil {
+			return "", err
+		}
+
+		for _, domainFile := range domainFiles {
+			info, err := domainFile.Info()
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&sb, "%s:%d:%d\n", path.Join(lp, domainFile.Name()), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return sb.String(), nil
+}
+
+// nplurals returns the number of plural forms specified in the 'Plural-Forms'-header of the locale.
+// The header is read from the 'default'-domain if it exists, otherwise from the first domain found.
+func (l *locale) nplurals() int {
+	if cat, ok := l.domains["default"]; ok {
+		return cat.NPlurals()
+	}
+
+	domains := make([]string, 0, len(l.domains))
+	for name := range l.domains {
+		domains = append(domains, name)
+	}
+	sort.Strings(domains)
+
+	for _, name := range domains {
+		if n := l.domains[name].NPlurals(); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// NewTemplateTranslator creates a new translator, initialized with available locales.
+// Catalogs that cannot be read or parsed are logged and skipped, use Validate to check them before they are deployed.
+// An error is only returned if the locale directory cannot be read, or the options are invalid.
+func NewTemplateTranslator(localeFS fs.FS, localePath string, options ...TranslatorOption) (*TemplateTranslator, error) {
+	t, err := newTemplateTranslator(localeFS, localePath, options)
 	if err != nil {
 		return nil, err
 	}
 
-	locales := map[string]*gotext.Locale{}
+	// Catalogs that cannot be read are logged and skipped, so that the other catalogs can still be used
+	err = t.Reload(func(err error) {
+		log.Printf("trans: skipping catalog: %v", err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// newTemplateTranslator creates a translator with the given options, without reading any catalogs
+func newTemplateTranslator(localeFS fs.FS, localePath string, options []TranslatorOption) (*TemplateTranslator, error) {
+	t := &TemplateTranslator{
+		localeFS:   localeFS,
+		localePath: localePath,
+		fallbacks:  map[string][]string{},
+	}
+	WithCatalogExtensions(defaultCatalogExtensions...)(t)
+
+	for _, option := range options {
+		option(t)
+	}
+
+	for ext := range t.extensions {
+		if !catalogExtensions[ext] {
+			return nil, fmt.Errorf("unsupported catalog extension '%s'", ext)
+		}
+	}
+	return t, nil
+}
+
+// loadLocales reads all catalogs with one of the given extensions from the locale directory.
+// If a catalog cannot be read, or a domain has more than one catalog, the error is passed to onError,
+// and the catalog is copied from previous (if available)
+func loadLocales(localeFS fs.FS, localePath string, extensions map[string]bool, previous map[string]*locale, onError func(error)) (map[string]*locale, []string, error) {
+	localeDirs, err := fs.ReadDir(localeFS, localePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locales := map[string]*locale{}
+	var languages []string
 	for _, dirEntry := range localeDirs {
+		if !dirEntry.IsDir() {
+			continue
+		}
 		localeName := dirEntry.Name()
 
 		lp := path.Join(localePath, localeName)
 		domainFiles, err := fs.ReadDir(localeFS, lp)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		l := gotext.NewLocale("", localeName)
-		if err != nil {
-			return nil, err
-		}
-
-		domainMap := map[string]bool{}
+		// The catalogs are grouped by domain, to find domains with more than one catalog
+		var domains []string
+		files := map[string][]string{}
 		for _, domainFile := range domainFiles {
 			if domainFile.IsDir() {
 				continue
 			}
 
 			n := domainFile.Name()
-			isPo := strings.HasSuffix(n, ".po")
-			isMo := strings.HasSuffix(n, ".mo")
-
-			if strings.HasPrefix(n, ".") ||
-				(!isPo && !(isMo)) {
+			ext := path.Ext(n)
+			if strings.HasPrefix(n, ".") || !extensions[ext] {
 				continue
 			}
 
-			// trim suffix
-			domainName := n[:len(n)-len(".po")]
+			domainName := strings.TrimSuffix(n, ext)
+			if files[domainName] == nil {
+				domains = append(domains, domainName)
+			}
+			files[domainName] = append(files[domainName], n)
+		}
 
-			if domainMap[domainName] {
-				// We've already loaded either the .PO or .MO file
-				continue
+		l := &locale{domains: map[string]*catalog.Catalog{}, fluent: map[string]bool{}}
+		for _, domainName := range domains {
+			var cat *catalog.Catalog
+			names := files[domainName]
+			if len(names) > 1 && !isCompiledPair(names) {
+				err = fmt.Errorf("%s: the domain '%s' has more than one catalog: %s", lp, domainName, strings.Join(names, ", "))
+			} else {
+				// If a .po-file has been compiled to a .mo-file, the .po-file (sorted last) is used, since it's the one
+				// that is edited, and it also contains the untranslated and fuzzy messages that are counted by Stats
+				cat, err = loadCatalog(localeFS, path.Join(lp, names[len(names)-1]), localeName)
 			}
 
-			contents, err := fs.ReadFile(localeFS, path.Join(lp, n))
 			if err != nil {
-				return nil, err
-			}
+				if onError != nil {
+					onError(err)
+				}
 
-			var tr gotext.Translator
-			if isPo {
-				po := gotext.NewPo()
-				po.Parse(contents)
-				tr = po
-			} else {
-				mo := gotext.NewMo()
-				mo.Parse(contents)
-				tr = mo
+				// Keep the previously loaded catalog, if we have one
+				if prev, ok := previous[localeName]; ok && prev.domains[domainName] != nil {
+					l.domains[domainName] = prev.domains[domainName]
+					l.fluent[domainName] = prev.fluent[domainName]
+				}
+				continue
 			}
-			l.AddTranslator(domainName, tr)
-			domainMap[domainName] = true
+			l.domains[domainName] = cat
+			l.fluent[domainName] = path.Ext(names[0]) == ".ftl"
 		}
 
-		//// If we don't have a default domain, we'll set the first one as default
-		//if !domainMap["default"] {
-		//	dom := l.GetDomain()
-		//	if dom != "" && l.Domains[dom] != nil {
-		//		l.AddTranslator("default", l.Domains[dom])
-		//	}
-		//}
-
 		locales[localeName] = l
+		languages = append(languages, localeName)
 	}
+	sort.Strings(languages)
 
 	// If our directory matches a regional locale, we'll have to
 	// map it to a general locale as well (if we don't have one)
-	additionalLocales := map[string]*gotext.Locale{}
+	additionalLocales := map[string]*locale{}
 	for name, locale := range locales {
 		parts := strings.Split(name, "_")
 		if len(parts) > 1 {
@@ -187,6 +564,42 @@ func NewTemplateTranslator(localeFS fs.FS, localePath string) (*TemplateTranslat
 		locales[name] = locale
 	}
 
-	t := &TemplateTranslator{locales: locales}
-	return t, nil
+	return locales, languages, nil
+}
+
+// catalogExtensions contains the extensions of the files read by loadCatalog
+var catalogExtensions = map[string]bool{".po": true, ".mo": true, ".json": true, ".xlf": true, ".xliff": true, ".ftl": true}
+
+// defaultCatalogExtensions contains the extensions read unless WithCatalogExtensions is used
+var defaultCatalogExtensions = []string{".po", ".mo"}
+
+// isCompiledPair checks if the catalogs of a domain are a .po-file and a .mo-file
+func isCompiledPair(names []string) bool {
+	return len(names) == 2 && path.Ext(names[0]) == ".mo" && path.Ext(names[1]) == ".po"
+}
+
+// loadCatalog reads and parses a single .po, .mo, .json, .xlf or .ftl-file
+func loadCatalog(localeFS fs.FS, filename string, language string) (*catalog.Catalog, error) {
+	contents, err := fs.ReadFile(localeFS, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cat *catalog.Catalog
+	switch path.Ext(filename) {
+	case ".po":
+		cat, err = catalog.ParsePo(contents)
+	case ".json":
+		cat, err = catalog.ParseJSON(contents, language)
+	case ".xlf", ".xliff":
+		cat, err = catalog.ParseXLIFF(contents)
+	case ".ftl":
+		cat, err = catalog.ParseFluent(contents, language)
+	default:
+		cat, err = catalog.ParseMo(contents)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return cat, nil
 }
@@ -1,10 +1,18 @@
 package trans
 
 import (
+	"bytes"
+	"context"
 	"embed"
+	"log"
+	"os"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/yzzyx/pongo-trans/catalog"
 )
 
 //go:embed testdata/locales
@@ -27,3 +35,324 @@ func TestNewTemplateTranslator(t *testing.T) {
 	// But the other domain should still work as expected
 	require.Equal(t, "Hello from the other domain!", tt.Get(TransCtx{Language: "en_GB", Domain: "other"}, "Hello world!"))
 }
+
+func TestTemplateTranslator_Languages(t *testing.T) {
+	tt, err := NewTemplateTranslator(localeTestdata, "testdata/locales")
+	require.Nil(t, err)
+
+	// Aliases such as 'sv' for 'sv_SE' should not be listed
+	require.Equal(t, []LanguageInfo{
+		{Code: "en_GB", Name: "British English", NameLocal: "British English", NameTranslated: "British English", Direction: "ltr", NPlurals: 2},
+		{Code: "sv_SE", Name: "Swedish (Sweden)", NameLocal: "svenska (Sverige)", NameTranslated: "Swedish (Sweden)", Direction: "ltr", NPlurals: 2},
+	}, tt.Languages())
+
+	// The number of plurals should also be available for aliases
+	require.Equal(t, 2, tt.LanguageInfo("sv").NPlurals)
+	// Unknown languages does not have any catalog header
+	require.Equal(t, 0, tt.LanguageInfo("de").NPlurals)
+}
+
+func TestTemplateTranslator_Stats(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": &fstest.MapFile{Data: []byte(`msgid "Hello world!"
+msgstr "Hej världen!"
+
+#, fuzzy
+msgid "Hello"
+msgstr "Hej"
+
+msgid "Untranslated"
+msgstr ""
+
+#~ msgid "Obsolete"
+#~ msgstr "Föråldrad"
+`)},
+		"locales/sv_SE/other.po":   testCatalog("Hej!"),
+		"locales/de_DE/default.po": testCatalog(""),
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+
+	// Aliases such as 'sv' for 'sv_SE' should not be listed
+	require.Equal(t, []TranslationStats{
+		{Language: "de_DE", Domain: "default", Stats: catalog.Stats{Total: 1, Untranslated: 1}, Percent: 0},
+		{Language: "sv_SE", Domain: "default", Stats: catalog.Stats{Total: 3, Translated: 1, Fuzzy: 1, Untranslated: 1}, Percent: 100.0 / 3},
+		{Language: "sv_SE", Domain: "other", Stats: catalog.Stats{Total: 1, Translated: 1}, Percent: 100},
+	}, tt.Stats())
+
+	// Fuzzy messages are not used, in the same way as when the catalog is compiled to a .mo-file
+	require.Equal(t, "Hej världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+	require.Equal(t, "Hello", tt.Get(TransCtx{Language: "sv_SE"}, "Hello"))
+
+	// The statistics are counted from the .po-file, even if it has been compiled to a .mo-file,
+	// which only contains the translated messages
+	compiled, err := catalog.ParsePo(localeFS["locales/sv_SE/default.po"].Data)
+	require.Nil(t, err)
+	var mo bytes.Buffer
+	require.Nil(t, catalog.WriteMo(&mo, compiled))
+	localeFS["locales/sv_SE/default.mo"] = &fstest.MapFile{Data: mo.Bytes()}
+	require.Nil(t, tt.Reload(nil))
+	require.Equal(t, catalog.Stats{Total: 3, Translated: 1, Fuzzy: 1, Untranslated: 1}, tt.Stats()[1].Stats)
+}
+
+func TestTemplateTranslator_JSON(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.json": &fstest.MapFile{Data: []byte(`{
+			"Hello world!": "Hej världen!",
+			"month": {"may": "maj"},
+			"May_month": "Maj",
+			"{{ n }} file_one": "{{ n }} fil",
+			"{{ n }} file_other": "{{ n }} filer",
+			"friend_male_one": "en vän",
+			"friend_male_other": "{{ n }} vänner"
+		}`)},
+		"locales/sv_SE/other.po": testCatalog("Hej från other!"),
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales", WithCatalogExtensions(".po", ".json"))
+	require.Nil(t, err)
+
+	ctx := TransCtx{Language: "sv_SE"}
+	require.Equal(t, "Hej världen!", tt.Get(ctx, "Hello world!"))
+	require.Equal(t, "maj", tt.Get(ctx, "month.may"))
+	require.Equal(t, "Maj", tt.GetC(ctx, "May", "month"))
+	require.Equal(t, "May", tt.GetC(ctx, "May", "name"))
+	require.Equal(t, "{{ n }} fil", tt.GetN(ctx, "{{ n }} file", "{{ n }} files", 1))
+	require.Equal(t, "{{ n }} filer", tt.GetN(ctx, "{{ n }} file", "{{ n }} files", 2))
+	require.Equal(t, "{{ n }} vänner", tt.GetNC(ctx, "friend", "friends", 3, "male"))
+	require.Equal(t, "friends", tt.GetNC(ctx, "friend", "friends", 3, "female"))
+	require.Equal(t, "Hej från other!", tt.Get(TransCtx{Language: "sv_SE", Domain: "other"}, "Hello world!"))
+	require.Equal(t, 2, tt.LanguageInfo("sv_SE").NPlurals)
+}
+
+func TestTemplateTranslator_XLIFF(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.xlf": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="sv-SE">
+  <file id="f1">
+    <unit id="1">
+      <segment state="translated"><source>Hello world!</source><target>Hej världen!</target></segment>
+    </unit>
+    <group id="2" type="x-gettext:plurals">
+      <unit id="2-0"><segment state="translated"><source>One file</source><target>En fil</target></segment></unit>
+      <unit id="2-1"><segment state="translated"><source>Many files</source><target>Flera filer</target></segment></unit>
+    </group>
+  </file>
+</xliff>`)},
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales", WithCatalogExtensions(".xlf"))
+	require.Nil(t, err)
+
+	ctx := TransCtx{Language: "sv_SE"}
+	require.Equal(t, "Hej världen!", tt.Get(ctx, "Hello world!"))
+	require.Equal(t, "En fil", tt.GetN(ctx, "One file", "Many files", 1))
+	require.Equal(t, "Flera filer", tt.GetN(ctx, "One file", "Many files", 3))
+}
+
+func TestTemplateTranslator_CatalogExtensions(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po":    testCatalog("Hej världen!"),
+		"locales/sv_SE/manifest.json": &fstest.MapFile{Data: []byte(`{"name": "not a catalog"`)},
+		"locales/sv_SE/default.ftl":   &fstest.MapFile{Data: []byte("hello = Hej från Fluent!\n")},
+	}
+
+	// Only .po- and .mo-files are read by default
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	require.Equal(t, "Hej världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	// A .po-file and the .mo-file compiled from it can be used together, and the .po-file is used
+	compiled, err := catalog.ParsePo([]byte("msgid \"Hello world!\"\nmsgstr \"Hej från mo!\"\n"))
+	require.Nil(t, err)
+	var mo bytes.Buffer
+	require.Nil(t, catalog.WriteMo(&mo, compiled))
+	localeFS["locales/sv_SE/default.mo"] = &fstest.MapFile{Data: mo.Bytes()}
+	require.Nil(t, tt.Reload(nil))
+	require.Equal(t, "Hej världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	// ... so changes to the .po-file are reloaded without compiling it again
+	localeFS["locales/sv_SE/default.po"] = testCatalog("Hallå världen!")
+	require.Nil(t, tt.Reload(nil))
+	require.Equal(t, "Hallå världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+	localeFS["locales/sv_SE/default.po"] = testCatalog("Hej världen!")
+	require.Nil(t, tt.Reload(nil))
+
+	// Other domains with more than one catalog are reported, and the previous catalog is kept
+	delete(localeFS, "locales/sv_SE/manifest.json")
+	localeFS["locales/sv_SE/default.json"] = &fstest.MapFile{Data: []byte(`{"Hello world!": "Hej från JSON!"}`)}
+	logged := captureLog(t)
+	tt, err = NewTemplateTranslator(localeFS, "locales", WithCatalogExtensions(".po", ".json"))
+	require.Nil(t, err)
+	require.Contains(t, logged.String(), "default.json, default.po")
+	require.Equal(t, "Hello world!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	delete(localeFS, "locales/sv_SE/default.json")
+	tt, err = NewTemplateTranslator(localeFS, "locales", WithCatalogExtensions(".po", ".json"))
+	require.Nil(t, err)
+	localeFS["locales/sv_SE/default.json"] = &fstest.MapFile{Data: []byte(`{"Hello world!": "Hej från JSON!"}`)}
+	var errs []error
+	require.Nil(t, tt.Reload(func(err error) { errs = append(errs, err) }))
+	require.Len(t, errs, 1)
+	require.Equal(t, "Hej världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	// Unsupported extensions are not accepted
+	_, err = NewTemplateTranslator(localeFS, "locales", WithCatalogExtensions(".txt"))
+	require.NotNil(t, err)
+}
+
+func testCatalog(msgstr string) *fstest.MapFile {
+	return &fstest.MapFile{
+		Data:    []byte("msgid \"Hello world!\"\nmsgstr \"" + msgstr + "\"\n"),
+		ModTime: time.Now(),
+	}
+}
+
+func TestTemplateTranslator_Reload(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": testCatalog("Hej världen!"),
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	require.Equal(t, "Hej världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+
+	// Updated and new catalogs should be loaded
+	localeFS["locales/sv_SE/default.po"] = testCatalog("Hallå världen!")
+	localeFS["locales/de_DE/default.po"] = testCatalog("Hallo Welt!")
+	require.Nil(t, tt.Reload(nil))
+	require.Equal(t, "Hallå världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+	require.Equal(t, "Hallo Welt!", tt.Get(TransCtx{Language: "de"}, "Hello world!"))
+
+	// Catalogs with errors should be reported, and the previous version kept
+	localeFS["locales/sv_SE/default.po"] = &fstest.MapFile{Data: []byte("msgid \"Hello world!\"\nmsgstr \"Hej")}
+	localeFS["locales/de_DE/default.po"] = testCatalog("Hallo, Welt!")
+	var errs []error
+	require.Nil(t, tt.Reload(func(err error) { errs = append(errs, err) }))
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "locales/sv_SE/default.po")
+	require.Equal(t, "Hallå världen!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+	require.Equal(t, "Hallo, Welt!", tt.Get(TransCtx{Language: "de_DE"}, "Hello world!"))
+
+	// Removed locales should not be available anymore
+	delete(localeFS, "locales/de_DE/default.po")
+	delete(localeFS, "locales/de_DE")
+	require.Nil(t, tt.Reload(nil))
+	require.Equal(t, "Hello world!", tt.Get(TransCtx{Language: "de_DE"}, "Hello world!"))
+	require.Len(t, tt.Languages(), 1)
+
+	// Invalid catalogs are logged and skipped when the translator is created
+	localeFS["locales/de_DE/default.po"] = testCatalog("Hallo Welt!")
+	logged := captureLog(t)
+	tt, err = NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+	require.Contains(t, logged.String(), "locales/sv_SE/default.po")
+	require.Equal(t, "Hello world!", tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!"))
+	require.Equal(t, "Hallo Welt!", tt.Get(TransCtx{Language: "de_DE"}, "Hello world!"))
+}
+
+// captureLog redirects the standard logger to a buffer until the test has finished
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestTemplateTranslator_Watch(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": testCatalog("Hej världen!"),
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales")
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tt.Watch(ctx, time.Millisecond, nil)
+	}()
+
+	// Translations should be available while the catalogs are reloaded
+	for i := 0; i < 100; i++ {
+		tr := tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!")
+		require.Contains(t, []string{"Hej världen!", "Hallå världen!"}, tr)
+	}
+
+	// fstest.MapFS is not safe for concurrent use, so stop watching before it's modified
+	cancel()
+	wg.Wait()
+
+	localeFS["locales/sv_SE/default.po"] = testCatalog("Hallå världen!")
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go tt.Watch(ctx, time.Millisecond, nil)
+
+	require.Eventually(t, func() bool {
+		return tt.Get(TransCtx{Language: "sv_SE"}, "Hello world!") == "Hallå världen!"
+	}, time.Second, time.Millisecond)
+}
+
+func TestTemplateTranslator_Fallback(t *testing.T) {
+	po := func(header string, messages ...string) *fstest.MapFile {
+		data := "msgid \"\"\nmsgstr \"" + header + "\"\n"
+		for _, m := range messages {
+			data += "\n" + m + "\n"
+		}
+		return &fstest.MapFile{Data: []byte(data)}
+	}
+
+	localeFS := fstest.MapFS{
+		"locales/pt_BR/default.po": po(``,
+			"msgid \"Hello\"\nmsgstr \"Olá\"",
+			"msgid \"Bus\"\nmsgstr \"Ônibus\""),
+		"locales/pt_PT/default.po": po(`Plural-Forms: nplurals=2; plural=(n != 1);\n`,
+			"msgid \"Hello\"\nmsgstr \"Olá!\"",
+			"msgid \"Bus\"\nmsgstr \"Autocarro\"",
+			"msgid \"Train\"\nmsgstr \"Comboio\"",
+			"msgid \"%d day\"\nmsgid_plural \"%d days\"\nmsgstr[0] \"%d dia\"\nmsgstr[1] \"%d dias\""),
+		"locales/nn_NO/default.po": po(``, "msgid \"Train\"\nmsgstr \"Tog\""),
+		"locales/en_GB/default.po": po(``,
+			"msgid \"Train\"\nmsgstr \"Railway train\"",
+			"msgid \"Colour\"\nmsgstr \"Colour\""),
+		"locales/ru_RU/default.po": po(`Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n`,
+			"msgid \"%d day\"\nmsgid_plural \"%d days\"\nmsgstr[0] \"%d день\"\nmsgstr[1] \"%d дня\"\nmsgstr[2] \"\""),
+	}
+
+	tt, err := NewTemplateTranslator(localeFS, "locales",
+		WithFallback("pt_BR", "pt_PT"),
+		WithFallback("nb_NO", "no", "nn", "en"),
+		WithFallback("ru", "pt_PT"),
+		WithDefaultFallback("en_GB"),
+	)
+	require.Nil(t, err)
+
+	ptBR := TransCtx{Language: "pt_BR"}
+	// Translated messages should be used as is
+	require.Equal(t, "Olá", tt.Get(ptBR, "Hello"))
+	// Missing messages are translated by the next language in the chain
+	require.Equal(t, "Comboio", tt.Get(ptBR, "Train"))
+	require.Equal(t, "5 dias", tt.GetN(ptBR, "%d day", "%d days", 5, 5))
+	// The default chain is not used for languages with a chain of their own
+	require.Equal(t, "Colour", tt.Get(ptBR, "Colour"))
+
+	// Languages without catalogs are skipped
+	require.Equal(t, "Tog", tt.Get(TransCtx{Language: "nb_NO"}, "Train"))
+	require.Equal(t, "Bus", tt.Get(TransCtx{Language: "nb_NO"}, "Bus"))
+
+	// Chains for general languages are used for regional variants,
+	// and each catalog uses its own plural forms
+	ruRU := TransCtx{Language: "ru_RU"}
+	require.Equal(t, "3 дня", tt.GetN(ruRU, "%d day", "%d days", 3, 3))
+	// Untranslated plural forms fall back
+	require.Equal(t, "5 dias", tt.GetN(ruRU, "%d day", "%d days", 5, 5))
+	// Unless the message is missing in all catalogs
+	require.Equal(t, "5 days", tt.GetNC(ruRU, "%d day", "%d days", 5, "other", 5))
+
+	// The default chain is used for all other languages
+	require.Equal(t, "Railway train", tt.Get(TransCtx{Language: "de_DE"}, "Train"))
+	require.Equal(t, "Hello", tt.Get(TransCtx{Language: "de_DE"}, "Hello"))
+}
@@ -0,0 +1,188 @@
+package trans
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+// ValidationIssue describes a problem found in a catalog
+type ValidationIssue struct {
+	Language string
+	Domain   string
+	Context  string
+	ID       string // Empty if the problem concerns the whole catalog, e.g. a malformed header
+	Problem  string
+}
+
+func (i ValidationIssue) String() string {
+	var sb strings.Builder
+	if i.Language != "" {
+		fmt.Fprintf(&sb, "%s/%s: ", i.Language, i.Domain)
+	}
+	if i.Context != "" {
+		fmt.Fprintf(&sb, "msgctxt %q ", i.Context)
+	}
+	if i.ID != "" {
+		fmt.Fprintf(&sb, "msgid %q: ", i.ID)
+	}
+	sb.WriteString(i.Problem)
+	return sb.String()
+}
+
+// Validate reads all catalogs in the same way as NewTemplateTranslator, and checks them with ValidateCatalog.
+// Catalogs that cannot be read are reported as issues, so that all catalogs are always checked.
+// Options, e.g. WithCatalogExtensions, are used in the same way as by NewTemplateTranslator.
+// An error is only returned if the locale directory cannot be read, or the options are invalid.
+func Validate(localeFS fs.FS, localePath string, options ...TranslatorOption) ([]ValidationIssue, error) {
+	t, err := newTemplateTranslator(localeFS, localePath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []ValidationIssue
+	onError := func(err error) {
+		issues = append(issues, ValidationIssue{Problem: err.Error()})
+	}
+
+	locales, languages, err := loadLocales(localeFS, localePath, t.extensions, nil, onError)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, language := range languages {
+		l := locales[language]
+
+		var domains []string
+		for domain := range l.domains {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+
+		for _, domain := range domains {
+			for _, issue := range ValidateCatalog(l.domains[domain]) {
+				issue.Language = language
+				issue.Domain = domain
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// ValidateCatalog checks a catalog for problems that would break rendering of the translations:
+//
+//   - Placeholders (e.g. '{{ name }}') in a translation that are not used in the original message, or the other way around
+//   - Translations that cannot be parsed as templates
+//   - Plural messages with a missing translation for one of the plural forms
+//   - A missing or malformed 'Plural-Forms'-header
+//
+// Untranslated and obsolete messages are not checked.
+func ValidateCatalog(cat *catalog.Catalog) []ValidationIssue {
+	var issues []ValidationIssue
+	if err := cat.CheckPluralForms(); err != nil {
+		issues = append(issues, ValidationIssue{Problem: err.Error()})
+	}
+
+	missingPluralForms := false
+	for _, m := range cat.Messages {
+		if m.Obsolete || (m.ID == "" && m.Context == "") || !isTranslated(m) {
+			continue
+		}
+
+		add := func(format string, args ...interface{}) {
+			issues = append(issues, ValidationIssue{Context: m.Context, ID: m.ID, Problem: fmt.Sprintf(format, args...)})
+		}
+
+		original := variables(m.ID, m.IDPlural)
+		translated := variables(m.Str...)
+		for _, name := range translated.names {
+			if !original.set[name] {
+				add("placeholder {{ %s }} in msgstr is not used in msgid", name)
+			}
+		}
+		for _, name := range original.names {
+			if !translated.set[name] {
+				add("placeholder {{ %s }} is missing in msgstr", name)
+			}
+		}
+
+		for k, str := range m.Str {
+			if !strings.Contains(str, "{") {
+				continue
+			}
+
+			templateMutex.Lock()
+			_, err := pongo2.FromString(str)
+			templateMutex.Unlock()
+			if err != nil {
+				if len(m.Str) > 1 {
+					add("msgstr[%d] cannot be parsed: %v", k, err)
+				} else {
+					add("msgstr cannot be parsed: %v", err)
+				}
+			}
+		}
+
+		if m.IDPlural == "" {
+			continue
+		}
+
+		nplurals := cat.NPlurals()
+		if nplurals <= 0 {
+			if !missingPluralForms && cat.Header("Plural-Forms") == "" {
+				issues = append(issues, ValidationIssue{Problem: "Plural-Forms is missing, but the catalog contains plural messages"})
+				missingPluralForms = true
+			}
+			continue
+		}
+
+		for k := 0; k < nplurals; k++ {
+			if k >= len(m.Str) || m.Str[k] == "" {
+				add("msgstr[%d] is missing", k)
+			}
+		}
+		if len(m.Str) > nplurals {
+			add("msgstr has %d plural forms, but nplurals is %d", len(m.Str), nplurals)
+		}
+	}
+	return issues
+}
+
+// isTranslated checks if any form of a message has been translated
+func isTranslated(m *catalog.Message) bool {
+	for _, str := range m.Str {
+		if str != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type variableSet struct {
+	names []string // In the order they are first used
+	set   map[string]bool
+}
+
+// variables returns all normalized variables used in strs
+func variables(strs ...string) variableSet {
+	vs := variableSet{set: map[string]bool{}}
+	for _, str := range strs {
+		for _, syntax := range templateSyntax.FindAllString(str, -1) {
+			if !strings.HasPrefix(syntax, "{{") {
+				continue
+			}
+
+			name := normalizeVariable(syntax)
+			if !vs.set[name] {
+				vs.names = append(vs.names, name)
+				vs.set[name] = true
+			}
+		}
+	}
+	return vs
+}
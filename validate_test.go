@@ -0,0 +1,134 @@
+package trans
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yzzyx/pongo-trans/catalog"
+)
+
+func TestValidateCatalog(t *testing.T) {
+	type T struct {
+		po       string
+		expected []string
+	}
+
+	header := `msgid ""
+msgstr "Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+`
+	tests := []T{
+		{po: header + `msgid "Hello {{ name }}"
+msgstr "Hej {{name}}"
+
+msgid "Untranslated {{ name }}"
+msgstr ""
+
+msgid "{{ n }} file"
+msgid_plural "{{ n }} files"
+msgstr[0] "{{ n }} fil"
+msgstr[1] "{{ n }} filer"
+
+#~ msgid "Obsolete {{ x }}"
+#~ msgstr "Föråldrad"
+`},
+		{po: header + `msgid "Visited {{ visited }}"
+msgstr "Besökt {{ besökt }}"
+`, expected: []string{
+			`msgid "Visited {{ visited }}": placeholder {{ besökt }} in msgstr is not used in msgid`,
+			`msgid "Visited {{ visited }}": placeholder {{ visited }} is missing in msgstr`,
+			// pongo2 only allows ASCII letters in variable names
+			`msgid "Visited {{ visited }}": msgstr cannot be parsed: ` +
+				`[Error (where: parser) in <string> | Line 1 Col 15 near 'ökt }}'] '}}' expected`,
+		}},
+		{po: header + `msgctxt "menu"
+msgid "Hello"
+msgstr "Hej {% if %}"
+`, expected: []string{
+			`msgctxt "menu" msgid "Hello": msgstr cannot be parsed: ` +
+				`[Error (where: parser) in <string> | Line 1 Col 8 near 'if'] Unexpected EOF, expected a number, string, keyword or identifier.`,
+		}},
+		{po: header + `msgid "One file"
+msgid_plural "Many files"
+msgstr[0] "En fil"
+`, expected: []string{
+			`msgid "One file": msgstr[1] is missing`,
+		}},
+		{po: header + `msgid "One file"
+msgid_plural "Many files"
+msgstr[0] "En fil"
+msgstr[1] ""
+msgstr[2] "Flera filer"
+`, expected: []string{
+			`msgid "One file": msgstr[1] is missing`,
+			`msgid "One file": msgstr has 3 plural forms, but nplurals is 2`,
+		}},
+		{po: `msgid "One file"
+msgid_plural "Many files"
+msgstr[0] "En fil"
+msgstr[1] "Flera filer"
+`, expected: []string{
+			`Plural-Forms is missing, but the catalog contains plural messages`,
+		}},
+		{po: `msgid ""
+msgstr "Plural-Forms: nplurals=two; plural=n != 1;\n"
+`, expected: []string{
+			`invalid nplurals "two" in Plural-Forms`,
+		}},
+		{po: `msgid ""
+msgstr "Plural-Forms: nplurals=2; plural=n > 1 ? 2 : 0;\n"
+`, expected: []string{
+			`plural expression in Plural-Forms selects form 2 for n=2, but nplurals is 2`,
+		}},
+		{po: `msgid ""
+msgstr "Plural-Forms: nplurals=2; plural=n > 1 ? 2 : n;\n"
+`, expected: []string{
+			`unsupported plural expression "n > 1 ? 2 : n" in Plural-Forms`,
+		}},
+		{po: `msgid ""
+msgstr "Plural-Forms: nplurals=2;\n"
+`, expected: []string{
+			`plural expression is missing in Plural-Forms`,
+		}},
+	}
+
+	for k, tst := range tests {
+		cat, err := catalog.ParsePo([]byte(tst.po))
+		require.Nilf(t, err, "test: %d", k)
+
+		var result []string
+		for _, issue := range ValidateCatalog(cat) {
+			result = append(result, issue.String())
+		}
+		require.Equalf(t, tst.expected, result, "test: %d", k)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	localeFS := fstest.MapFS{
+		"locales/sv_SE/default.po": &fstest.MapFile{Data: []byte(`msgid "Hello {{ name }}"
+msgstr "Hej {{ namn }}"
+`)},
+		"locales/sv_SE/other.po": &fstest.MapFile{Data: []byte(`msgid "Hello"
+msgstr "Hej"
+`)},
+		"locales/en_GB/default.po": &fstest.MapFile{Data: []byte(`msgid "Hello`)},
+	}
+
+	issues, err := Validate(localeFS, "locales")
+	require.Nil(t, err)
+
+	var result []string
+	for _, issue := range issues {
+		result = append(result, issue.String())
+	}
+	require.Equal(t, []string{
+		"locales/en_GB/default.po: line 1: expected a quoted string",
+		`sv_SE/default: msgid "Hello {{ name }}": placeholder {{ namn }} in msgstr is not used in msgid`,
+		`sv_SE/default: msgid "Hello {{ name }}": placeholder {{ name }} is missing in msgstr`,
+	}, result)
+
+	_, err = Validate(localeFS, "missing")
+	require.NotNil(t, err)
+}